@@ -0,0 +1,47 @@
+package lexer_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// Once the buffers have grown, NextTokenInto() must allocate nothing but the
+// copies of token bytes, whatever the source.  lexSpaces doesn't count lines,
+// so the file's table of line starts doesn't grow either
+func TestNextTokenIntoAllocs(t *testing.T) {
+	const tokens = 10000
+	for _, c := range []struct {
+		name   string
+		new    func() lexer.Lexer
+		copies bool
+	}{
+		{"New", func() lexer.Lexer { return lexer.New(lexSpaces, bytes.NewReader(benchInput), 1) }, true},
+		{"NewSize", func() lexer.Lexer { return lexer.NewSize(lexSpaces, bytes.NewReader(benchInput), 4096, 1) }, true},
+		{"NewFromBytes", func() lexer.Lexer { return lexer.NewFromBytes(lexSpaces, benchInput, 1) }, true},
+		{"NewFromBytesNoCopy", func() lexer.Lexer { return lexer.NewFromBytesNoCopy(lexSpaces, benchInput, 1) }, false},
+	} {
+		lex := c.new()
+		var tok lexer.Token
+		for i := 0; i < 1000; i++ {
+			lex.NextTokenInto(&tok)
+		}
+		copies := 0
+		allocs := testing.AllocsPerRun(1, func() {
+			copies = 0
+			for i := 0; i < tokens; i++ {
+				lex.NextTokenInto(&tok)
+				if c.copies && tok.Bytes() != nil {
+					copies++
+				}
+			}
+		})
+		if tok.EOF() {
+			t.Fatalf("%s: reached EOF, benchInput is too short", c.name)
+		}
+		if allocs != float64(copies) {
+			t.Errorf("%s: NextTokenInto() allocated %v times for %d tokens with %d copies of their bytes", c.name, allocs, tokens, copies)
+		}
+	}
+}
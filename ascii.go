@@ -0,0 +1,87 @@
+package lexer
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// asciiSet is a bitmap of the ASCII bytes within a match set, allowing runs
+// of ASCII input to be matched byte-by-byte without decoding runes
+type asciiSet [2]uint64
+
+// makeASCIISet builds an asciiSet from the ASCII bytes in match
+func makeASCIISet(match []byte) asciiSet {
+	var s asciiSet
+	for _, b := range match {
+		if b < utf8.RuneSelf {
+			s[b>>6] |= 1 << (b & 63)
+		}
+	}
+	return s
+}
+
+// contains returns true if the ASCII byte b is in the set
+func (s *asciiSet) contains(b byte) bool {
+	return s[b>>6]&(1<<(b&63)) != 0
+}
+
+// cachedSet is an asciiSet, and the data pointer and length of the slice it
+// was built from
+type cachedSet struct {
+	data *byte
+	len  int
+	set  asciiSet
+}
+
+// asciiSetOf returns the asciiSet of match, cached by the data pointer and
+// length of its slice, as states usually match the same few slices over and
+// over
+func (l *lexer) asciiSetOf(match []byte) asciiSet {
+	if len(match) == 0 {
+		return asciiSet{}
+	}
+	for i := range l.sets {
+		if c := &l.sets[i]; c.data == &match[0] && c.len == len(match) {
+			return c.set
+		}
+	}
+	c := &l.sets[l.nextSet]
+	l.nextSet = (l.nextSet + 1) % len(l.sets)
+	c.data, c.len, c.set = &match[0], len(match), makeASCIISet(match)
+	return c.set
+}
+
+// matchBytes consumes a run of up to max runes (max <= 0 meaning no limit)
+// whose membership in match equals want, returning the number of runes
+// consumed.  Runs of ASCII already in the peek buffer are scanned directly,
+// falling back to decoding runes for non-ASCII input and buffer refills
+func (l *lexer) matchBytes(match []byte, want bool, max int) int {
+	set := l.asciiSetOf(match)
+	count := 0
+	for max <= 0 || count < max {
+		if !l.tooLong && !l.aborted {
+			p := l.peekBytes
+			i := l.tokenLen
+			for i < len(p) && p[i] < utf8.RuneSelf && set.contains(p[i]) == want && (max <= 0 || count < max) {
+				i++
+				count++
+			}
+			l.nextASCII(i - l.tokenLen)
+			if max > 0 && count >= max {
+				break
+			}
+		}
+		r := l.PeekRune(0)
+		if r == RuneEOF || (bytes.IndexRune(match, r) >= 0) != want {
+			break
+		}
+		l.NextRune()
+		count++
+	}
+	return count
+}
+
+// nextASCII consumes n ASCII bytes from the peek buffer, see nextBytes()
+func (l *lexer) nextASCII(n int) {
+	l.nextBytes(l.tokenLen + n)
+}
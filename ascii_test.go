@@ -0,0 +1,186 @@
+package lexer_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// matchers abstracts over the []byte matchers, which scan runs of ASCII
+// directly, and the []rune matchers, which decode every rune
+type matchers struct {
+	oneOrMore    func(l lexer.Lexer, set string) bool
+	nonOneOrMore func(l lexer.Lexer, set string) bool
+	minMax       func(l lexer.Lexer, set string, min int, max int) bool
+	zeroOrOne    func(l lexer.Lexer, set string) bool
+	nonOne       func(l lexer.Lexer, set string) bool
+}
+
+var byteMatchers = matchers{
+	oneOrMore:    func(l lexer.Lexer, set string) bool { return l.MatchOneOrMoreBytes([]byte(set)) },
+	nonOneOrMore: func(l lexer.Lexer, set string) bool { return l.NonMatchOneOrMoreBytes([]byte(set)) },
+	minMax: func(l lexer.Lexer, set string, min int, max int) bool {
+		return l.MatchMinMaxBytes([]byte(set), min, max)
+	},
+	zeroOrOne: func(l lexer.Lexer, set string) bool { return l.MatchZeroOrOneBytes([]byte(set)) },
+	nonOne:    func(l lexer.Lexer, set string) bool { return l.NonMatchOneBytes([]byte(set)) },
+}
+
+// sharedSets slices the sets that lexWith matches from one array, so that
+// some start at the same address, and differ only in length
+var sharedSets = func() map[string][]byte {
+	all := letters + digits + spaces + ","
+	pool := []byte(all)
+	return map[string][]byte{
+		letters: pool[:len(letters)],
+		digits:  pool[len(letters) : len(letters)+len(digits)],
+		spaces:  pool[len(letters)+len(digits) : len(all)-1],
+		all:     pool,
+		"":      pool[:0],
+	}
+}()
+
+// sharedMatchers are the []byte matchers, given sharedSets
+var sharedMatchers = matchers{
+	oneOrMore:    func(l lexer.Lexer, set string) bool { return l.MatchOneOrMoreBytes(sharedSets[set]) },
+	nonOneOrMore: func(l lexer.Lexer, set string) bool { return l.NonMatchOneOrMoreBytes(sharedSets[set]) },
+	minMax: func(l lexer.Lexer, set string, min int, max int) bool {
+		return l.MatchMinMaxBytes(sharedSets[set], min, max)
+	},
+	zeroOrOne: func(l lexer.Lexer, set string) bool { return l.MatchZeroOrOneBytes(sharedSets[set]) },
+	nonOne:    func(l lexer.Lexer, set string) bool { return l.NonMatchOneBytes(sharedSets[set]) },
+}
+
+var runeMatchers = matchers{
+	oneOrMore:    func(l lexer.Lexer, set string) bool { return l.MatchOneOrMoreRunes([]rune(set)) },
+	nonOneOrMore: func(l lexer.Lexer, set string) bool { return l.NonMatchOneOrMoreRunes([]rune(set)) },
+	minMax: func(l lexer.Lexer, set string, min int, max int) bool {
+		return l.MatchMinMaxRunes([]rune(set), min, max)
+	},
+	zeroOrOne: func(l lexer.Lexer, set string) bool { return l.MatchZeroOrOneRunes([]rune(set)) },
+	nonOne:    func(l lexer.Lexer, set string) bool { return l.NonMatchOneRunes([]rune(set)) },
+}
+
+const (
+	letters = "abcdefghijklmnopqrstuvwxyz"
+	digits  = "0123456789"
+	spaces  = " \t\n"
+)
+
+// lexWith returns a lexer of numbers, words, spaces and punctuation, built
+// on m
+func lexWith(m matchers) lexer.StateFn {
+	var state lexer.StateFn
+	state = func(l lexer.Lexer) lexer.StateFn {
+		switch {
+		case l.MatchEOF():
+			l.EmitEOF()
+			return nil
+		case m.minMax(l, digits, 2, 4):
+			m.zeroOrOne(l, letters)
+			l.EmitTokenWithBytes(lexer.T_EOF + 1)
+		case m.oneOrMore(l, letters):
+			l.EmitTokenWithBytes(lexer.T_EOF + 2)
+		case m.oneOrMore(l, spaces):
+			l.IgnoreToken()
+		case m.nonOneOrMore(l, letters+digits+spaces+","):
+			l.EmitTokenWithBytes(lexer.T_EOF + 3)
+		case m.nonOne(l, ""):
+			l.EmitTokenWithBytes(lexer.T_EOF + 4)
+		}
+		return state
+	}
+	return state
+}
+
+// recorder is a Tracer that records every event
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) TraceState(name string) { r.events = append(r.events, "state") }
+func (r *recorder) TraceNextRune(c rune, line int, column int) {
+	r.events = append(r.events, fmt.Sprintf("next %q@%d:%d", c, line, column))
+}
+func (r *recorder) TraceBackup(n int, line int, column int) {
+	r.events = append(r.events, fmt.Sprintf("backup %d@%d:%d", n, line, column))
+}
+func (r *recorder) TraceReset(m *lexer.Marker) { r.events = append(r.events, "reset") }
+func (r *recorder) TraceEmit(t *lexer.Token) {
+	r.events = append(r.events, fmt.Sprintf("emit %v[%d,%d)", t, t.Offset(), t.End()))
+}
+func (r *recorder) TraceIgnore(b []byte) { r.events = append(r.events, fmt.Sprintf("ignore %q", b)) }
+
+// sources builds each kind of source for input, including small-buffer
+// readers that refill in the middle of runs
+var sources = map[string]func(input []byte) lexer.Source{
+	"bytes":          func(input []byte) lexer.Source { return lexer.FromBytes(input) },
+	"reader":         func(input []byte) lexer.Source { return lexer.FromReader(bytes.NewReader(input)) },
+	"small reader":   func(input []byte) lexer.Source { return lexer.FromReader(bytes.NewReader(input)) },
+	"one byte reads": func(input []byte) lexer.Source { return lexer.FromReader(iotest.OneByteReader(bytes.NewReader(input))) },
+}
+
+// run lexes input, returning the tokens and, if traced, the trace
+func run(start lexer.StateFn, source string, input []byte, traced bool) (string, string) {
+	var opts []lexer.Option
+	if source != "bytes" && source != "reader" {
+		opts = append(opts, lexer.BufferSize(8))
+	}
+	var r recorder
+	if traced {
+		opts = append(opts, lexer.WithTracer(&r))
+	}
+	opts = append(opts, lexer.Newlines(lexer.NewlineLF), lexer.TabWidth(4))
+	lex := lexer.NewWithOptions(start, sources[source](input), opts...)
+	var tokens []string
+	for {
+		t := lex.NextToken()
+		tokens = append(tokens, fmt.Sprintf("%v[%d,%d)", t, t.Offset(), t.End()))
+		if t.EOF() {
+			break
+		}
+	}
+	return strings.Join(tokens, "\n"), strings.Join(r.events, "\n")
+}
+
+// randomInput returns input drawn from ASCII, multi-byte runes and invalid
+// UTF-8
+func randomInput(rnd *rand.Rand) []byte {
+	pieces := []string{"a", "bc", "x", "1", "42", "12345", " ", "\t", "\n", ",", ";", "é", "世界", "\xff", "\xc3", "\xe4\xb8"}
+	var b []byte
+	for n := rnd.Intn(64); n > 0; n-- {
+		b = append(b, pieces[rnd.Intn(len(pieces))]...)
+	}
+	return b
+}
+
+// The []byte matchers' ASCII fast path must match the rune path exactly,
+// traced or not, and however the sets' slices overlap
+func TestByteMatchersMatchRuneMatchers(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	lexBytes, lexRunes, lexShared := lexWith(byteMatchers), lexWith(runeMatchers), lexWith(sharedMatchers)
+	for i := 0; i < 500; i++ {
+		input := randomInput(rnd)
+		for source := range sources {
+			want, wantTrace := run(lexRunes, source, input, true)
+			got, gotTrace := run(lexBytes, source, input, true)
+			untraced, _ := run(lexBytes, source, input, false)
+			shared, _ := run(lexShared, source, input, false)
+			switch {
+			case shared != want:
+				t.Fatalf("%s %q: tokens differ with shared sets\n got %s\nwant %s", source, input, shared, want)
+			case got != want:
+				t.Fatalf("%s %q: tokens differ\n got %s\nwant %s", source, input, got, want)
+			case gotTrace != wantTrace:
+				t.Fatalf("%s %q: traces differ\n got %s\nwant %s", source, input, gotTrace, wantTrace)
+			case untraced != want:
+				t.Fatalf("%s %q: untraced tokens differ\n got %s\nwant %s", source, input, untraced, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package lexer
+
+// BalanceOptions controls MatchBalanced
+type BalanceOptions struct {
+
+	// Quotes lists the runes that start and end string literals, within
+	// which delimiters are ignored, such as `"'`.  Default none
+	Quotes string
+
+	// Escape is the rune that escapes the next rune within a string
+	// literal, such as '\\'.  Default 0, none
+	Escape rune
+}
+
+// opener records the position of an open delimiter, for error reporting
+type opener struct {
+	line   int
+	column int
+	offset int
+}
+
+// Lexer::MatchBalanced
+func (l *lexer) MatchBalanced(open string, close string, opts BalanceOptions) bool {
+	if !l.peekString(open) {
+		return false
+	}
+	var stack []opener
+	for {
+		switch {
+		case len(stack) > 0 && l.peekString(close):
+			l.nextString(close)
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return true
+			}
+		case l.peekString(open):
+			stack = append(stack, l.opener())
+			l.nextString(open)
+		default:
+			at := l.opener()
+			r := l.NextRune()
+			switch {
+			case r == RuneEOF:
+				o := stack[len(stack)-1]
+				l.emitErrAt("unmatched "+open, o.line, o.column, o.offset)
+				return false
+			case containsRune(opts.Quotes, r) && !l.matchQuoted(r, opts.Escape):
+				l.emitErrAt("unterminated string", at.line, at.column, at.offset)
+				return false
+			}
+		}
+	}
+}
+
+// matchQuoted consumes the rest of a string literal opened by quote,
+// returning false if it is unterminated
+func (l *lexer) matchQuoted(quote rune, escape rune) bool {
+	for {
+		switch r := l.NextRune(); {
+		case r == RuneEOF:
+			return false
+		case r == quote:
+			return true
+		case r == escape && escape != 0:
+			if l.NextRune() == RuneEOF {
+				return false
+			}
+		}
+	}
+}
+
+// opener returns the position of the next rune
+func (l *lexer) opener() opener {
+	return opener{line: l.line, column: l.column + 1, offset: l.offset + l.tokenLen}
+}
+
+// peekString returns true if the next runes match s
+func (l *lexer) peekString(s string) bool {
+	i := 0
+	for _, r := range s {
+		if l.PeekRune(i) != r {
+			return false
+		}
+		i++
+	}
+	return i > 0
+}
+
+// nextString consumes the runes of s, which must be next
+func (l *lexer) nextString(s string) {
+	for range s {
+		l.NextRune()
+	}
+}
+
+// containsRune returns true if s contains r
+func containsRune(s string, r rune) bool {
+	for _, c := range s {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package lexer_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// lexBalanced matches one balanced span with opts, emitting it as T_BODY,
+// and what follows it as T_WORD
+func lexBalanced(open, close string, opts lexer.BalanceOptions) lexer.StateFn {
+	return func(l lexer.Lexer) lexer.StateFn {
+		if l.MatchBalanced(open, close, opts) {
+			l.EmitTokenWithBytes(T_BODY)
+		}
+		if l.MatchOneOrMoreFunc(func(rune) bool { return true }) {
+			l.EmitTokenWithBytes(T_WORD)
+		}
+		l.EmitEOF()
+		return nil
+	}
+}
+
+// MatchBalanced consumes up to the matching close, skipping quoted strings,
+// or emits an error at the innermost unmatched open, or the unterminated
+// string
+func TestMatchBalanced(t *testing.T) {
+	quotes := lexer.BalanceOptions{Quotes: `"'`, Escape: '\\'}
+	for _, c := range []struct {
+		open, close string
+		opts        lexer.BalanceOptions
+		input       string
+		want        string // the tokens, less EOF, with their Pos and span
+	}{
+		{"(", ")", quotes, "x", `WORD("x")@1:1 1:1 [0,1)`},
+		{"(", ")", quotes, "() x", `BODY("()")@1:1 1:1 [0,2) WORD(" x")@1:3 1:3 [2,4)`},
+		{"(", ")", quotes, "(a (b) (c (d)))e", `BODY("(a (b) (c (d)))")@1:1 1:1 [0,15) WORD("e")@1:16 1:16 [15,16)`},
+		{"(", ")", quotes, "(a\n(b)\n)", `BODY("(a\n(b)\n)")@1:1 1:1 [0,8)`},
+		{"{{", "}}", quotes, "{{a {{b}} }c}}}", `BODY("{{a {{b}} }c}}")@1:1 1:1 [0,14) WORD("}")@1:15 1:15 [14,15)`},
+		{"(", ")", quotes, `(")" ')' "\")" (x))`, `BODY("(\")\" ')' \"\\\")\" (x))")@1:1 1:1 [0,19)`},
+		{"(", ")", lexer.BalanceOptions{}, `(")")"`, `BODY("(\")")@1:1 1:1 [0,3) WORD("\")\"")@1:4 1:4 [3,6)`},
+
+		{"(", ")", quotes, "(a (b) c", `LEX_ERR("unmatched (")@1:1 1:1 [0,8)`},
+		{"(", ")", quotes, "(a (b\n\t(c) d", `LEX_ERR("unmatched (")@1:4 1:4 [0,12)`},
+		{"(", ")", quotes, "(\n\t(a", `LEX_ERR("unmatched (")@2:5 2:5 [0,5)`},
+		{"(", ")", quotes, "(a\n\t\"b) \\\" c", `LEX_ERR("unterminated string")@2:5 2:5 [0,12)`},
+		{"(", ")", quotes, "(a 'b\\", `LEX_ERR("unterminated string")@1:4 1:4 [0,6)`},
+	} {
+		for source := range sources {
+			lex, fset := newSourceLexer(lexBalanced(c.open, c.close, c.opts), source, c.input)
+			var got []string
+			for tok := lex.NextToken(); !tok.EOF(); tok = lex.NextToken() {
+				got = append(got, fmt.Sprintf("%v %v [%d,%d)", tok, fset.Position(tok.Pos()), tok.Offset(), tok.End()))
+			}
+			if strings.Join(got, " ") != c.want {
+				t.Errorf("%q %s: got %s, want %s", c.input, source, strings.Join(got, " "), c.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package lexer_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"unicode"
+
+	"github.com/iNamik/go_lexer"
+	"github.com/iNamik/go_lexer/lexertest"
+)
+
+// benchInput is about 1MiB of words, numbers, punctuation and non-ASCII text,
+// over many lines
+var benchInput = func() []byte {
+	rnd := rand.New(rand.NewSource(1))
+	pieces := []string{"lexer", "state", "x", "token", "if", "return", "Größe", "naïve", "日本語", "42", "3.14", "0x1f", "==", "(", ")", "{", "}", ";"}
+	var b bytes.Buffer
+	for b.Len() < 1<<20 {
+		b.WriteString(pieces[rnd.Intn(len(pieces))])
+		if rnd.Intn(8) == 0 {
+			b.WriteByte('\n')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.Bytes()
+}()
+
+// lexRunes exercises the rune buffer with PeekRune, NextRune and BackupRune
+func lexRunes(l lexer.Lexer) lexer.StateFn {
+	r := l.NextRune()
+	switch {
+	case r == lexer.RuneEOF:
+		l.EmitEOF()
+		return nil
+	case r == '\n':
+		l.NewLine()
+		l.IgnoreToken()
+	case r == ' ':
+		l.IgnoreToken()
+	case unicode.IsLetter(r):
+		for unicode.IsLetter(l.PeekRune(0)) || unicode.IsDigit(l.PeekRune(0)) {
+			l.NextRune()
+		}
+		l.EmitTokenWithBytes(T_WORD)
+	case unicode.IsDigit(r):
+		for r = l.NextRune(); unicode.IsDigit(r) || r == '.' || r == 'x' || unicode.Is(unicode.ASCII_Hex_Digit, r); r = l.NextRune() {
+		}
+		l.BackupRune()
+		l.EmitTokenWithBytes(T_NUM)
+	default:
+		if r == '=' && l.PeekRune(0) == '=' {
+			l.NextRune()
+		}
+		l.EmitToken(lexer.T_UNKNOWN)
+	}
+	return lexRunes
+}
+
+// The byte sets matched by lexMatchers, declared once so that matching does
+// not allocate
+var (
+	spaceBytes  = []byte(" ")
+	numberBytes = []byte("0123456789.xabcdef")
+	blankBytes  = []byte(" \n")
+)
+
+// lexMatchers lexes the same input with the Match*() helpers
+func lexMatchers(l lexer.Lexer) lexer.StateFn {
+	switch {
+	case l.MatchEOF():
+		l.EmitEOF()
+		return nil
+	case l.MatchOneRune('\n'):
+		l.NewLine()
+		l.IgnoreToken()
+	case l.MatchOneOrMoreBytes(spaceBytes):
+		l.IgnoreToken()
+	case l.MatchOneOrMoreFunc(unicode.IsLetter):
+		l.EmitTokenWithBytes(T_WORD)
+	case l.MatchOneOrMoreBytes(numberBytes):
+		l.EmitTokenWithBytes(T_NUM)
+	default:
+		l.NonMatchOneOrMoreBytes(blankBytes)
+		l.EmitToken(lexer.T_UNKNOWN)
+	}
+	return lexMatchers
+}
+
+func BenchmarkRunes(b *testing.B) {
+	lexertest.BenchmarkAllocs(b, lexRunes, benchInput)
+}
+
+func BenchmarkMatchers(b *testing.B) {
+	lexertest.BenchmarkAllocs(b, lexMatchers, benchInput)
+}
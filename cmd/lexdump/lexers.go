@@ -0,0 +1,44 @@
+package main
+
+// Blank-import packages that register lexers here, i.e.
+//
+//	import _ "example.com/mylang/lexer"
+
+import "github.com/iNamik/go_lexer"
+
+// A simple lexer that splits input into words, spaces and newlines, useful
+// for checking that lexdump itself works
+func init() {
+	lexer.RegisterLexer("words", lexWords)
+}
+
+var (
+	T_SPACE   = lexer.RegisterTokenType("SPACE")
+	T_NEWLINE = lexer.RegisterTokenType("NEWLINE")
+	T_WORD    = lexer.RegisterTokenType("WORD")
+)
+
+var bytesNonWord = []byte{' ', '\t', '\f', '\v', '\n', '\r'}
+
+var bytesSpace = []byte{' ', '\t', '\f', '\v'}
+
+func lexWords(l lexer.Lexer) lexer.StateFn {
+	if l.MatchEOF() {
+		l.EmitEOF()
+		return nil
+	}
+
+	if l.NonMatchOneOrMoreBytes(bytesNonWord) {
+		l.EmitTokenWithBytes(T_WORD)
+	} else if l.MatchOneOrMoreBytes(bytesSpace) {
+		l.EmitTokenWithBytes(T_SPACE)
+	} else if l.MatchOneRune('\n') || (l.MatchOneRune('\r') && l.MatchZeroOrOneRune('\n')) {
+		l.EmitTokenWithBytes(T_NEWLINE)
+		l.NewLine()
+	} else {
+		l.NextRune()
+		l.EmitTokenWithBytes(lexer.T_UNKNOWN)
+	}
+
+	return lexWords
+}
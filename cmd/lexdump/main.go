@@ -0,0 +1,181 @@
+/*
+Command lexdump runs a registered lexer over a file (or stdin) and prints the
+emitted tokens, which is handy when debugging a misbehaving lexer.
+
+Usage:
+
+	lexdump [-format text|json|table] <lexer> [filename]
+
+Lexers are looked up by name in the registry of iNamik/go_lexer.  To make your
+own lexers available, blank-import the packages that register them (via
+lexer.RegisterLexer) in lexers.go and rebuild.
+*/
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+import "github.com/iNamik/go_lexer"
+
+var format = flag.String("format", "text", "output format: text, json or table")
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [-format text|json|table] <lexer> [filename]\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "registered lexers: %s\n", strings.Join(lexer.Lexers(), ", "))
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	startState, ok := lexer.LookupLexer(flag.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: unknown lexer %q\n", os.Args[0], flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	var input io.Reader = os.Stdin
+
+	if flag.NArg() == 2 {
+		file, err := os.Open(flag.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	p := newPrinter(*format, os.Stdout)
+	if p == nil {
+		fmt.Fprintf(os.Stderr, "%s: unknown format %q\n", os.Args[0], *format)
+		usage()
+		os.Exit(2)
+	}
+
+	if err := dump(p, startState, input); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+// dump lexes input with startState, printing every token with p
+func dump(p printer, startState lexer.StateFn, input io.Reader) error {
+	lex := lexer.New(startState, input, 1)
+
+	for {
+		t := lex.NextToken()
+		p.Print(t)
+		if t.EOF() {
+			break
+		}
+	}
+
+	return p.Flush()
+}
+
+// printer formats tokens for output
+type printer interface {
+	Print(*lexer.Token)
+	Flush() error
+}
+
+// newPrinter returns a printer of format to w, or nil if format is unknown
+func newPrinter(format string, w io.Writer) printer {
+	switch format {
+	case "text":
+		return &textPrinter{w: w}
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return &jsonPrinter{enc: enc}
+	case "table":
+		return &tablePrinter{w: tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)}
+	}
+	return nil
+}
+
+// textPrinter prints one token per line, using Token.String()
+type textPrinter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *textPrinter) Print(t *lexer.Token) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintln(p.w, t.String())
+	}
+}
+
+func (p *textPrinter) Flush() error { return p.err }
+
+// jsonPrinter prints one JSON object per line (JSON Lines)
+type jsonPrinter struct {
+	enc *json.Encoder
+	err error
+}
+
+type jsonToken struct {
+	Type   string      `json:"type"`
+	Line   int         `json:"line"`
+	Column int         `json:"column"`
+	Bytes  string      `json:"bytes"`
+	Base64 bool        `json:"base64,omitempty"` // Bytes is base64, as they are not valid UTF-8
+	Value  interface{} `json:"value,omitempty"`
+}
+
+func (p *jsonPrinter) Print(t *lexer.Token) {
+	if p.err != nil {
+		return
+	}
+	jt := jsonToken{Type: t.Type().String(), Line: t.Line(), Column: t.Column(), Bytes: string(t.Bytes()), Value: t.Value()}
+	if !utf8.Valid(t.Bytes()) {
+		jt.Bytes, jt.Base64 = base64.StdEncoding.EncodeToString(t.Bytes()), true
+	}
+	p.err = p.enc.Encode(jt)
+}
+
+func (p *jsonPrinter) Flush() error { return p.err }
+
+// tablePrinter prints aligned columns with a header
+type tablePrinter struct {
+	w      *tabwriter.Writer
+	header bool
+	err    error
+}
+
+func (p *tablePrinter) Print(t *lexer.Token) {
+	if p.err != nil {
+		return
+	}
+	if !p.header {
+		_, p.err = fmt.Fprintln(p.w, "TYPE\tLINE\tCOLUMN\tBYTES")
+		p.header = true
+	}
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, "%s\t%d\t%d\t%q\n", t.Type().String(), t.Line(), t.Column(), t.Bytes())
+	}
+}
+
+func (p *tablePrinter) Flush() error {
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
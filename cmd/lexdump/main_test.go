@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// Each format of the words lexer's tokens for testdata/words.in must match
+// testdata/words.<format>.  Run 'go test -update' to rewrite them
+func TestFormats(t *testing.T) {
+	for _, format := range []string{"text", "json", "table"} {
+		input, err := os.Open(filepath.Join("testdata", "words.in"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		err = dump(newPrinter(format, &out), lexWords, input)
+		input.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		golden := filepath.Join("testdata", "words."+format)
+		if *update {
+			if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", format, out.Bytes(), want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package lexer
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Lexer::MatchUntilString
+func (l *lexer) MatchUntilString(delim string, includeDelim bool) bool {
+	if delim == "" {
+		return true
+	}
+	d := []byte(delim)
+	for {
+		p := l.peekBytes
+		if i := bytes.Index(p[l.tokenLen:], d); i >= 0 {
+			end := l.tokenLen + i
+			// The delimiter was examined, even if it isn't consumed
+			l.reached(end + len(d))
+			if includeDelim {
+				end += len(d)
+			}
+			l.nextBytes(end)
+			return true
+		}
+		if !l.bufferFull() {
+			// So was the end of the input
+			l.reached(len(p) + 1)
+			l.nextBytes(len(p))
+			if l.tracer != nil {
+				l.tracer.TraceNextRune(RuneEOF, l.line, l.column+1)
+			}
+			return false
+		}
+		l.reached(len(p))
+		// Consume what can't be the start of the delimiter or a partial rune,
+		// then refill, growing the buffer
+		limit := len(p) - (len(d) - 1) - (utf8.UTFMax - 1)
+		for limit > l.tokenLen && !utf8.RuneStart(p[limit]) {
+			limit--
+		}
+		if limit > l.tokenLen {
+			l.nextBytes(limit)
+		}
+		if !l.ensureRuneLen(l.pos+len(p)-l.tokenLen+1) && (l.tooLong || l.aborted) {
+			return false
+		}
+	}
+}
+
+// Lexer::MatchCapture
+func (l *lexer) MatchCapture(match MatchFn) string {
+	start := l.tokenLen
+	l.MatchZeroOrMoreFunc(match)
+	return string(l.peekBytes[start:l.tokenLen])
+}
+
+// nextBytes consumes the runes in the peek buffer up to offset end, keeping
+// the rune buffer in step so that the runes can be backed up, and tracing
+// each rune as NextRune() would
+func (l *lexer) nextBytes(end int) {
+	for l.tokenLen < end {
+		if l.pos == len(l.runes) {
+			r, size := rune(l.peekBytes[l.tokenLen]), 1
+			if r >= utf8.RuneSelf {
+				r, size = utf8.DecodeRune(l.peekBytes[l.tokenLen:])
+			}
+			l.runes = append(l.runes, r)
+			l.runeEnds = append(l.runeEnds, l.tokenLen+size)
+			l.peekPos = l.tokenLen + size
+			l.reached(l.peekPos)
+		}
+		if l.tracer != nil {
+			l.tracer.TraceNextRune(l.runes[l.pos], l.line, l.column+1)
+		}
+		l.advance(l.runes[l.pos], l.runeEnds[l.pos])
+	}
+}
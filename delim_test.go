@@ -0,0 +1,129 @@
+package lexer_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// lexBrackets emits [[...]] bodies without their delimiters, and words
+func lexBrackets(l lexer.Lexer) lexer.StateFn {
+	switch {
+	case l.MatchOneOrMoreRunes([]rune{' '}):
+		l.IgnoreToken()
+	case l.MatchOneRune('[') && l.MatchOneRune('['):
+		l.IgnoreToken()
+		if !l.MatchUntilString("]]", false) {
+			l.EmitError("unterminated")
+			return lexBrackets
+		}
+		l.EmitTokenWithBytes(T_BODY)
+		l.MatchOneRune(']')
+		l.MatchOneRune(']')
+		l.IgnoreToken()
+	case l.NonMatchOneOrMoreRunes([]rune{' ', '['}):
+		l.EmitTokenWithBytes(T_WORD)
+	case l.MatchOneRune('['):
+		l.EmitTokenWithBytes(T_WORD)
+	default:
+		l.EmitEOF()
+		return nil
+	}
+	return lexBrackets
+}
+
+// Edits next to MatchUntilString() must re-lex the literal, as a full lex does
+func TestMatchUntilStringIncremental(t *testing.T) {
+	for _, c := range []struct {
+		input    string
+		offset   int
+		deleted  int
+		inserted string
+	}{
+		{"[[a]] b", 4, 2, "[ "},
+		{"[[a]] b", 3, 1, "x"},
+		{"x [[abc", 7, 0, " more"},
+		{"x [[abc", 7, 0, "]]"},
+		{"[[a]]", 5, 0, "]"},
+	} {
+		inc := lexer.NewIncremental(lexBrackets, []byte(c.input))
+		inc.Edit(c.offset, c.deleted, []byte(c.inserted))
+		want := lexer.NewIncremental(lexBrackets, inc.Input()).Tokens()
+		if got := inc.Tokens(); formatTokens(got) != formatTokens(want) {
+			t.Errorf("%q edited to %q:\n got\n%swant\n%s", c.input, inc.Input(), formatTokens(got), formatTokens(want))
+		}
+	}
+}
+
+// lexUntil matches up to delim with MatchUntilString(), recording whether it
+// was found, and emits what it matched as T_BODY, and what follows as T_WORD
+func lexUntil(delim string, includeDelim bool, found *bool) lexer.StateFn {
+	return func(l lexer.Lexer) lexer.StateFn {
+		if *found = l.MatchUntilString(delim, includeDelim); len(l.PeekTokenBytes()) > 0 {
+			l.EmitTokenWithBytes(T_BODY)
+		}
+		if l.MatchOneOrMoreFunc(func(rune) bool { return true }) {
+			l.EmitTokenWithBytes(T_WORD)
+		}
+		l.EmitEOF()
+		return nil
+	}
+}
+
+// MatchUntilString finds delimiters, and counts lines and columns (in
+// bytes), across refills of a small buffer, whatever runes the refills split
+func TestMatchUntilString(t *testing.T) {
+	readers := map[string]func(input string) lexer.Source{
+		"bytes":  func(input string) lexer.Source { return lexer.FromString(input) },
+		"reader": func(input string) lexer.Source { return lexer.FromReader(strings.NewReader(input)) },
+		"one byte reads": func(input string) lexer.Source {
+			return lexer.FromReader(iotest.OneByteReader(strings.NewReader(input)))
+		},
+	}
+	for _, c := range []struct {
+		input        string
+		delim        string
+		includeDelim bool
+		want         string
+	}{
+		{"ab]]cd", "]]", false, `true BODY("ab")@1:1 WORD("]]cd")@1:3 EOF@1:7`},
+		{"ab]]cd", "]]", true, `true BODY("ab]]")@1:1 WORD("cd")@1:5 EOF@1:7`},
+		{"]]", "]]", false, `true WORD("]]")@1:1 EOF@1:3`},
+		{"abcdefg]]h]]", "]]", true, `true BODY("abcdefg]]")@1:1 WORD("h]]")@1:10 EOF@1:13`},
+		{"日本語]]x", "]]", false, `true BODY("日本語")@1:1 WORD("]]x")@1:10 EOF@1:13`},
+		{"a日本]語]]", "]]", true, `true BODY("a日本]語]]")@1:1 EOF@1:14`},
+		{"aé」b」c", "」", true, `true BODY("aé」")@1:1 WORD("b」c")@1:7 EOF@1:12`},
+		{"aé」b」c", "b」", false, `true BODY("aé」")@1:1 WORD("b」c")@1:7 EOF@1:12`},
+		{"x\n日本\n語é]]y", "]]", false, `true BODY("x\n日本\n語é")@1:1 WORD("]]y")@3:6 EOF@3:9`},
+		{"日本語abc]", "]]", false, `false BODY("日本語abc]")@1:1 EOF@1:14`},
+		{"", "]]", false, `false EOF@1:1`},
+	} {
+		// Each rune is traced as NextRune() would, as with no refills
+		var trace string
+		for _, name := range []string{"bytes", "reader", "one byte reads"} {
+			var found bool
+			var r recorder
+			fset := lexer.NewFileSet()
+			lex := lexer.NewWithOptions(lexUntil(c.delim, c.includeDelim, &found), readers[name](c.input),
+				lexer.BufferSize(4), lexer.Newlines(lexer.NewlineLF), lexer.WithFileSet(fset), lexer.WithTracer(&r))
+			var got []string
+			for _, tok := range allTokens(lex) {
+				if p := fset.Position(tok.Pos()); p.Line != tok.Line() || p.Column != tok.Column() {
+					t.Errorf("%q %s: %v resolves to %v", c.input, name, &tok, p)
+				}
+				got = append(got, tok.String())
+			}
+			if got := fmt.Sprint(found, " ", strings.Join(got, " ")); got != c.want {
+				t.Errorf("%q %q %v %s: got %s, want %s", c.input, c.delim, c.includeDelim, name, got, c.want)
+			}
+			if name == "bytes" {
+				trace = strings.Join(r.events, "\n")
+			} else if got := strings.Join(r.events, "\n"); got != trace {
+				t.Errorf("%q %q %v %s: traced\n%s\nwant\n%s", c.input, c.delim, c.includeDelim, name, got, trace)
+			}
+		}
+	}
+}
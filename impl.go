@@ -1,31 +1,28 @@
 package lexer
 
-import (
-	"bytes"
-	"unicode/utf8"
-)
-
 import (
 	"github.com/iNamik/go_pkg/runes"
 )
 
 // Lexer::NextToken - Returns the next token from the reader.
 func (l *lexer) NextToken() *Token {
-	for {
-		select {
-		case token := <-l.tokens:
-			return token
-		default:
-			l.state = l.state(l)
-		}
+	t := new(Token)
+	l.NextTokenInto(t)
+	return t
+}
+
+// Lexer::NextTokenInto - Reads the next token from the reader into t.
+func (l *lexer) NextTokenInto(t *Token) {
+	for !l.receive(t) {
+		l.step()
 	}
-	panic("not reached")
 }
 
 // Lexer::NewLine
 func (l *lexer) NewLine() {
 	l.line++
 	l.column = 0
+	l.file.AddLine(l.base + l.offset + l.tokenLen)
 }
 
 // Lexer::Line
@@ -38,6 +35,16 @@ func (l *lexer) Column() int {
 	return l.column
 }
 
+// Lexer::Filename
+func (l *lexer) Filename() string {
+	return l.filename
+}
+
+// Lexer::FileSet
+func (l *lexer) FileSet() *FileSet {
+	return l.fset
+}
+
 // Lexer:PeekRune
 func (l *lexer) PeekRune(n int) rune {
 	ok := l.ensureRuneLen(l.pos + n + 1) // Correct for 0-based 'n'
@@ -46,9 +53,7 @@ func (l *lexer) PeekRune(n int) rune {
 		return RuneEOF
 	}
 
-	i := l.runes.Peek(l.pos + n)
-
-	return i.(rune)
+	return l.runes[l.pos+n]
 }
 
 // Lexer::NextRune
@@ -56,18 +61,19 @@ func (l *lexer) NextRune() rune {
 	ok := l.ensureRuneLen(l.pos + 1)
 
 	if !ok {
+		if l.tracer != nil {
+			l.tracer.TraceNextRune(RuneEOF, l.line, l.column+1)
+		}
 		return RuneEOF
 	}
 
-	i := l.runes.Peek(l.pos) // 0-based
-
-	r := i.(rune)
+	r := l.runes[l.pos] // 0-based
 
-	l.pos++
-
-	l.tokenLen += utf8.RuneLen(r)
+	if l.tracer != nil {
+		l.tracer.TraceNextRune(r, l.line, l.column+1)
+	}
 
-	l.column += utf8.RuneLen(r)
+	l.advance(r, l.runeEnds[l.pos])
 
 	return r
 }
@@ -79,20 +85,34 @@ func (l *lexer) BackupRune() {
 
 // Lexer::BackupRunes
 func (l *lexer) BackupRunes(n int) {
-	for ; n > 0; n-- {
+	for c := n; c > 0; c-- {
 		if l.pos > 0 {
 			l.pos--
 
-			i := l.runes.Peek(l.pos) // 0-based
-			r := i.(rune)
+			start := 0
+			if l.pos > 0 {
+				start = l.runeEnds[l.pos-1]
+			}
 
-			l.tokenLen -= utf8.RuneLen(r)
+			if l.trackPositions {
+				p := l.positions[l.pos]
+				l.line, l.column, l.prevRune = p.line, p.column, p.prevRune
+			} else {
+				l.column -= l.tokenLen - start
+			}
 
-			l.column -= utf8.RuneLen(r)
+			l.tokenLen = start
 		} else {
 			panic("Underflow Exception")
 		}
 	}
+	// A value no longer matches the token once its runes are backed up
+	if l.tokenLen < l.valueEnd {
+		l.value = nil
+	}
+	if l.tracer != nil {
+		l.tracer.TraceBackup(n, l.line, l.column+1)
+	}
 }
 
 // Lexer::PeekTokenBytes
@@ -100,7 +120,6 @@ func (l *lexer) PeekTokenBytes() []byte {
 	return l.peekBytes[0:l.tokenLen]
 }
 
-
 // Lexer::EmitToken
 func (l *lexer) EmitToken(t TokenType) {
 	l.emit(t, false)
@@ -111,6 +130,12 @@ func (l *lexer) EmitTokenWithBytes(t TokenType) {
 	l.emit(t, true)
 }
 
+// Lexer::EmitTokenWithValue
+func (l *lexer) EmitTokenWithValue(t TokenType, value interface{}) {
+	l.value = value
+	l.emit(t, false)
+}
+
 // Lexer::EmitToken
 func (l *lexer) EmitEOF() {
 	l.emit(T_EOF, false)
@@ -122,17 +147,23 @@ func (l *lexer) EmitError(err string) {
 
 // Lexer::IgnoreToken
 func (l *lexer) IgnoreToken() {
-	l.consume(false)
+	if l.overflowed(ignoredMatch) {
+		return
+	}
+	if l.tracer != nil {
+		l.tracer.TraceIgnore(l.PeekTokenBytes())
+	}
+	l.trivium(T_UNKNOWN)
 }
 
 // Lexer::Marker
 func (l *lexer) Marker() *Marker {
-	return &Marker{sequence: l.sequence, pos: l.pos, tokenLen: l.tokenLen, line: l.line, column: l.column}
+	return &Marker{sequence: l.sequence, pos: l.pos, tokenLen: l.tokenLen, line: l.line, column: l.column, prevRune: l.prevRune, value: l.value, valueEnd: l.valueEnd}
 }
 
 // Lexer::CanReset
 func (l *lexer) CanReset(m *Marker) bool {
-	return m.sequence == l.sequence && m.pos <= l.runes.Len() && m.tokenLen <= l.peekPos
+	return m.sequence == l.sequence && m.pos <= len(l.runes) && m.tokenLen <= l.peekPos
 }
 
 // Lexer::Reset
@@ -147,13 +178,19 @@ func (l *lexer) Reset(m *Marker) {
 	l.line = m.line
 
 	l.column = m.column
+
+	l.prevRune = m.prevRune
+
+	l.value, l.valueEnd = m.value, m.valueEnd
+
+	if l.tracer != nil {
+		l.tracer.TraceReset(m)
+	}
 }
 
 // Lexer::MatchZeroOrOneBytes
 func (l *lexer) MatchZeroOrOneBytes(match []byte) bool {
-	if r := l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) >= 0 {
-		l.NextRune()
-	}
+	l.matchBytes(match, true, 1)
 	return true
 }
 
@@ -183,9 +220,7 @@ func (l *lexer) MatchZeroOrOneFunc(match MatchFn) bool {
 
 // Lexer::MatchZeroOrMoreBytes
 func (l *lexer) MatchZeroOrMoreBytes(match []byte) bool {
-	for r := l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) >= 0; r = l.PeekRune(0) {
-		l.NextRune()
-	}
+	l.matchBytes(match, true, 0)
 	return true
 }
 
@@ -207,11 +242,7 @@ func (l *lexer) MatchZeroOrMoreFunc(match MatchFn) bool {
 
 // Lexer::MatchOneBytes
 func (l *lexer) MatchOneBytes(match []byte) bool {
-	if r := l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) >= 0 {
-		l.NextRune()
-		return true
-	}
-	return false
+	return l.matchBytes(match, true, 1) == 1
 }
 
 // Lexer::MatchOneRunes
@@ -243,15 +274,7 @@ func (l *lexer) MatchOneFunc(match MatchFn) bool {
 
 // Lexer::MatchOneOrMoreBytes
 func (l *lexer) MatchOneOrMoreBytes(match []byte) bool {
-	var r rune
-	if r = l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) >= 0 {
-		l.NextRune()
-		for r = l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) >= 0; r = l.PeekRune(0) {
-			l.NextRune()
-		}
-		return true
-	}
-	return false
+	return l.matchBytes(match, true, 0) > 0
 }
 
 // Lexer::MatchOneOrMoreRunes
@@ -283,15 +306,7 @@ func (l *lexer) MatchOneOrMoreFunc(match MatchFn) bool {
 // Lexer::MatchMinMaxBytes
 func (l *lexer) MatchMinMaxBytes(match []byte, min int, max int) bool {
 	marker := l.Marker()
-	count := 0
-	for r := l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) >= 0; r = l.PeekRune(0) {
-		l.NextRune()
-		count++
-		if max > 0 && count >= max { // Check here to avoid unused PeekRune()
-			break
-		}
-	}
-	if count < min {
+	if l.matchBytes(match, true, max) < min {
 		l.Reset(marker)
 		return false
 	}
@@ -336,11 +351,7 @@ func (l *lexer) MatchMinMaxFunc(match MatchFn, min int, max int) bool {
 
 // Lexer::NonMatchOneBytes
 func (l *lexer) NonMatchOneBytes(match []byte) bool {
-	if r := l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) == -1 {
-		l.NextRune()
-		return true
-	}
-	return false
+	return l.matchBytes(match, false, 1) == 1
 }
 
 // Lexer::NonMatchOneRunes
@@ -363,15 +374,7 @@ func (l *lexer) NonMatchOneFunc(match MatchFn) bool {
 
 // Lexer::NonMatchOneOrMoreBytes
 func (l *lexer) NonMatchOneOrMoreBytes(match []byte) bool {
-	var r rune
-	if r = l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) == -1 {
-		l.NextRune()
-		for r = l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) == -1; r = l.PeekRune(0) {
-			l.NextRune()
-		}
-		return true
-	}
-	return false
+	return l.matchBytes(match, false, 0) > 0
 }
 
 // Lexer::NonMatchOneOrMoreRunes
@@ -402,9 +405,7 @@ func (l *lexer) NonMatchOneOrMoreFunc(match MatchFn) bool {
 
 // Lexer::NonMatchZeroOrOneBytes
 func (l *lexer) NonMatchZeroOrOneBytes(match []byte) bool {
-	if r := l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) == -1 {
-		l.NextRune()
-	}
+	l.matchBytes(match, false, 1)
 	return true
 }
 
@@ -426,9 +427,7 @@ func (l *lexer) NonMatchZeroOrOneFunc(match MatchFn) bool {
 
 // Lexer::NonMatchZeroOrMoreBytes
 func (l *lexer) NonMatchZeroOrMoreBytes(match []byte) bool {
-	for r := l.PeekRune(0); r != RuneEOF && bytes.IndexRune(match, r) == -1; r = l.PeekRune(0) {
-		l.NextRune()
-	}
+	l.matchBytes(match, false, 0)
 	return true
 }
 
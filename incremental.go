@@ -0,0 +1,274 @@
+package lexer
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// Incremental keeps the tokens of an input up to date as it is edited,
+// re-lexing only as much of the input as an edit affects.
+//
+// At each token boundary it records a checkpoint of the lexer: the state,
+// mode stack, line and column, and how far ahead the lexer looked.  Edit()
+// resumes from the last checkpoint unaffected by the edit, and stops once the
+// lexer reaches a checkpoint it recorded before with the same state, mode
+// stack and column.  States are compared by function, so a lexer whose states
+// are closures over differing values may re-synchronize too early.
+//
+// Tokens keep their Offset, End, Line and Column up to date, but not Pos
+type Incremental struct {
+	startState  StateFn
+	opts        []Option
+	input       []byte
+	tokens      []Token
+	checkpoints []checkpoint
+}
+
+// TokenRange describes the tokens replaced by an edit: the old tokens
+// [Start, OldEnd) were replaced by the new tokens [Start, NewEnd)
+type TokenRange struct {
+	Start  int
+	OldEnd int
+	NewEnd int
+}
+
+// checkpoint records the state of the lexer at a token boundary
+type checkpoint struct {
+	offset   int
+	index    int // number of tokens before the checkpoint
+	state    StateFn
+	modes    []StateFn
+	line     int
+	column   int
+	prevRune rune
+	reach    int // offset following the furthest byte examined before the next checkpoint
+
+	// With KeepTrivia, the token held for its trailing trivia, and the trivia
+	// for the next token
+	holding bool
+	held    Token
+	trivia  []Token
+}
+
+// NewIncremental lexes input with startState and the specified options,
+// returning an Incremental for editing it
+func NewIncremental(startState StateFn, input []byte, opts ...Option) *Incremental {
+	inc := &Incremental{startState: startState, opts: opts, input: input}
+	inc.tokens, inc.checkpoints, _ = inc.run(inc.newLexer(input), 0, nil)
+	return inc
+}
+
+// Input returns the current input
+func (inc *Incremental) Input() []byte { return inc.input }
+
+// Tokens returns the tokens of the current input, ending with EOF
+func (inc *Incremental) Tokens() []Token { return inc.tokens }
+
+// Edit replaces deleted bytes at offset with inserted, re-lexes the affected
+// part of the input and returns the range of tokens that changed
+func (inc *Incremental) Edit(offset int, deleted int, inserted []byte) TokenRange {
+	if offset < 0 || deleted < 0 || offset+deleted > len(inc.input) {
+		panic("lexer: Edit out of range")
+	}
+	input := make([]byte, 0, len(inc.input)-deleted+len(inserted))
+	input = append(input, inc.input[:offset]...)
+	input = append(input, inserted...)
+	input = append(input, inc.input[offset+deleted:]...)
+	delta := len(inserted) - deleted
+
+	// Restart from the first checkpoint whose lexing examined the edited bytes
+	j := 0
+	for j < len(inc.checkpoints)-1 && inc.checkpoints[j].reach <= offset {
+		j++
+	}
+	from := inc.checkpoints[j]
+
+	l := inc.newLexer(input)
+	l.offset = from.offset
+	l.line = from.line
+	l.column = from.column
+	l.prevRune = from.prevRune
+	l.state = from.state
+	l.modes = append(l.modes, from.modes...)
+	l.holding = from.holding
+	l.held = from.held
+	l.trivia = from.trivia[:len(from.trivia):len(from.trivia)]
+	l.reach = from.offset
+	l.updatePeekBytes()
+
+	// Stop once the lexer reaches an old checkpoint after the edit.  Without
+	// tab stops, the rest of the checkpoint's line can be moved sideways
+	var lines, line, columns int
+	sync := func(c *checkpoint) int {
+		old := c.offset - delta
+		if c.offset < offset+len(inserted) || old < offset+deleted {
+			return -1
+		}
+		for k := j; k < len(inc.checkpoints) && inc.checkpoints[k].offset <= old; k++ {
+			o := &inc.checkpoints[k]
+			if o.offset != old || (o.column != c.column && l.tabWidth > 0) || o.prevRune != c.prevRune || !sameState(o.state, c.state) || !sameModes(o.modes, c.modes) {
+				continue
+			}
+			lines, line, columns = c.line-o.line, o.line, c.column-o.column
+			if o.holding == c.holding && (!o.holding || sameToken(shiftToken(o.held, delta, lines, line, columns), c.held)) &&
+				sameTokens(shiftTokens(o.trivia, delta, lines, line, columns), c.trivia) {
+				return k
+			}
+		}
+		return -1
+	}
+	tokens, checkpoints, k := inc.run(l, from.index, sync)
+
+	r := TokenRange{Start: from.index, OldEnd: len(inc.tokens), NewEnd: from.index + len(tokens)}
+	newTokens := append(append([]Token(nil), inc.tokens[:from.index]...), tokens...)
+	newCheckpoints := append(append([]checkpoint(nil), inc.checkpoints[:j]...), checkpoints...)
+	if k >= 0 {
+		to := inc.checkpoints[k]
+		r.OldEnd = to.index
+		for _, t := range inc.tokens[to.index:] {
+			newTokens = append(newTokens, shiftToken(t, delta, lines, line, columns))
+		}
+		for _, c := range inc.checkpoints[k:] {
+			if c.line == line {
+				c.column += columns
+			}
+			if c.holding {
+				c.held = shiftToken(c.held, delta, lines, line, columns)
+			}
+			c.trivia = shiftTokens(c.trivia, delta, lines, line, columns)
+			c.offset += delta
+			c.index += r.NewEnd - r.OldEnd
+			c.line += lines
+			c.reach += delta
+			newCheckpoints = append(newCheckpoints, c)
+		}
+	}
+	inc.input = input
+	inc.tokens = newTokens
+	inc.checkpoints = newCheckpoints
+	return r
+}
+
+// newLexer returns a lexer for input
+func (inc *Incremental) newLexer(input []byte) *lexer {
+	c := newConfig(inc.opts)
+	c.base = 0
+	return newLexer(inc.startState, FromBytes(input), &c)
+}
+
+// run collects tokens and checkpoints from l until EOF, or until sync returns
+// the index of an old checkpoint to re-synchronize with.  index is the number
+// of tokens before the lexer's current offset
+func (inc *Incremental) run(l *lexer, index int, sync func(*checkpoint) int) ([]Token, []checkpoint, int) {
+	var tokens []Token
+	var checkpoints []checkpoint
+	var t Token
+	for {
+		for l.receive(&t) {
+			tokens = append(tokens, shiftToken(t, 0, 0, 0, 0))
+			if t.typ == T_EOF {
+				if n := len(checkpoints); n > 0 {
+					checkpoints[n-1].reach = l.reach
+				}
+				return tokens, checkpoints, -1
+			}
+		}
+		if l.atBoundary() {
+			if n := len(checkpoints); n > 0 {
+				checkpoints[n-1].reach = l.reach
+			}
+			l.reach = l.offset + l.peekPos
+			c := checkpoint{
+				offset:   l.offset,
+				index:    index + len(tokens),
+				state:    l.state,
+				modes:    append([]StateFn(nil), l.modes...),
+				line:     l.line,
+				column:   l.column,
+				prevRune: l.prevRune,
+				holding:  l.holding,
+				held:     l.held,
+				trivia:   l.trivia,
+			}
+			if sync != nil {
+				if k := sync(&c); k >= 0 {
+					return tokens, checkpoints, k
+				}
+			}
+			checkpoints = append(checkpoints, c)
+		}
+		l.step()
+	}
+}
+
+// atBoundary returns true if the lexer is between tokens in its original
+// input, so that lexing can be resumed from its current state
+func (l *lexer) atBoundary() bool {
+	return l.tokenLen == 0 && len(l.inputs) == 0 && len(l.txs) == 0 && !l.eof && !l.aborted && !l.skipTail && l.state != nil
+}
+
+// shiftToken returns a copy of the token, and its trivia, moved by delta
+// bytes and lines lines, and by columns columns if on line line, without a Pos
+func shiftToken(t Token, delta int, lines int, line int, columns int) Token {
+	if t.line == line {
+		t.column += columns
+	}
+	t.offset += delta
+	t.end += delta
+	t.line += lines
+	t.pos = NoPos
+	if t.extra != nil {
+		t.setExtra(shiftTokens(t.extra.leading, delta, lines, line, columns), shiftTokens(t.extra.trailing, delta, lines, line, columns), t.extra.value)
+	}
+	return t
+}
+
+// shiftTokens returns a copy of the tokens, moved as per shiftToken
+func shiftTokens(tokens []Token, delta int, lines int, line int, columns int) []Token {
+	if tokens == nil {
+		return nil
+	}
+	shifted := make([]Token, len(tokens))
+	for i := range tokens {
+		shifted[i] = shiftToken(tokens[i], delta, lines, line, columns)
+	}
+	return shifted
+}
+
+// sameState returns true if a and b are the same function
+func sameState(a StateFn, b StateFn) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// sameModes returns true if the mode stacks hold the same states
+func sameModes(a []StateFn, b []StateFn) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameState(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameToken returns true if the tokens, and their trivia, are the same
+// apart from their Pos
+func sameToken(a Token, b Token) bool {
+	return a.typ == b.typ && a.offset == b.offset && a.end == b.end && a.line == b.line && a.column == b.column &&
+		bytes.Equal(a.bytes, b.bytes) && sameTokens(a.LeadingTrivia(), b.LeadingTrivia()) && sameTokens(a.TrailingTrivia(), b.TrailingTrivia())
+}
+
+// sameTokens returns true if the lists of tokens are the same, see sameToken
+func sameTokens(a []Token, b []Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameToken(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
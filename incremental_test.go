@@ -0,0 +1,149 @@
+package lexer_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// delimiters end words in lexOuter and lexInner
+var delimiters = []byte(" \t\r\n()")
+
+// lexOuter emits words, and pushes itself on the mode stack to lex the
+// contents of parentheses with lexInner
+func lexOuter(l lexer.Lexer) lexer.StateFn {
+	switch {
+	case l.MatchEOF():
+		l.EmitEOF()
+		return nil
+	case l.MatchOneOrMoreBytes(blanks):
+		l.EmitTrivia(T_SPACE)
+	case l.MatchOneRune('('):
+		l.EmitTokenWithBytes(lexer.T_UNKNOWN)
+		l.PushMode(lexOuter)
+		return lexInner
+	case l.MatchOneRune(')'):
+		l.EmitTokenWithBytes(lexer.T_UNKNOWN)
+	default:
+		l.NonMatchOneOrMoreBytes(delimiters)
+		l.EmitTokenWithBytes(T_WORD)
+	}
+	return lexOuter
+}
+
+// lexInner emits words within parentheses as T_BODY, nesting, and returns to
+// the mode it was pushed from at the closing parenthesis
+func lexInner(l lexer.Lexer) lexer.StateFn {
+	switch {
+	case l.MatchEOF():
+		l.EmitEOF()
+		return nil
+	case l.MatchOneOrMoreBytes(blanks):
+		l.EmitTrivia(T_SPACE)
+	case l.MatchOneRune('('):
+		l.EmitTokenWithBytes(lexer.T_UNKNOWN)
+		l.PushMode(lexInner)
+	case l.MatchOneRune(')'):
+		l.EmitTokenWithBytes(lexer.T_UNKNOWN)
+		return l.PopMode()
+	default:
+		l.NonMatchOneOrMoreBytes(delimiters)
+		l.EmitTokenWithBytes(T_BODY)
+	}
+	return lexInner
+}
+
+// formatWithTrivia formats tokens as formatTokens does, followed by their
+// leading (<) and trailing (>) trivia
+func formatWithTrivia(tokens []lexer.Token) string {
+	s := ""
+	for i := range tokens {
+		s += formatTokens(tokens[i : i+1])
+		for _, t := range tokens[i].LeadingTrivia() {
+			s += "< " + formatTokens([]lexer.Token{t})
+		}
+		for _, t := range tokens[i].TrailingTrivia() {
+			s += "> " + formatTokens([]lexer.Token{t})
+		}
+	}
+	return s
+}
+
+// After each of a series of random edits, the tokens must be those of
+// lexing the edited input afresh, and the TokenRange must cover every token
+// that changed
+func TestIncrementalEdits(t *testing.T) {
+	const alphabet = "ab(\t) \r\n"
+	random := func(rnd *rand.Rand, n int) []byte {
+		b := make([]byte, rnd.Intn(n+1))
+		for i := range b {
+			b[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		return b
+	}
+	for name, opts := range map[string][]lexer.Option{
+		"default":    nil,
+		"KeepTrivia": {lexer.KeepTrivia()},
+		"TabWidth":   {lexer.TabWidth(4), lexer.Newlines(lexer.NewlineLF)},
+		"NewlineAny": {lexer.Newlines(lexer.NewlineAny)},
+		"all":        {lexer.KeepTrivia(), lexer.TabWidth(4), lexer.Newlines(lexer.NewlineAny)},
+	} {
+		rnd := rand.New(rand.NewSource(1))
+		for run := 0; run < 20; run++ {
+			inc := lexer.NewIncremental(lexOuter, random(rnd, 40), opts...)
+			for edit := 0; edit < 50; edit++ {
+				input := inc.Input()
+				offset := rnd.Intn(len(input) + 1)
+				deleted := rnd.Intn(len(input) - offset + 1)
+				if deleted > 4 {
+					deleted = 4
+				}
+				inserted := random(rnd, 4)
+				old := append([]lexer.Token(nil), inc.Tokens()...)
+				r := inc.Edit(offset, deleted, inserted)
+
+				desc := fmt.Sprintf("%s: Edit(%d, %d, %q) of %q", name, offset, deleted, inserted, input)
+				got := inc.Tokens()
+				if want := lexer.NewIncremental(lexOuter, inc.Input(), opts...).Tokens(); formatWithTrivia(got) != formatWithTrivia(want) {
+					t.Fatalf("%s: got\n%swant\n%s", desc, formatWithTrivia(got), formatWithTrivia(want))
+				}
+				if r.Start > r.OldEnd || r.Start > r.NewEnd || r.OldEnd > len(old) || r.NewEnd > len(got) || len(old)-r.OldEnd != len(got)-r.NewEnd {
+					t.Fatalf("%s: %+v is not a valid range of %d old and %d new tokens", desc, r, len(old), len(got))
+				}
+				if formatWithTrivia(old[:r.Start]) != formatWithTrivia(got[:r.Start]) {
+					t.Fatalf("%s: %+v, but tokens before Start changed", desc, r)
+				}
+				for i := range old[r.OldEnd:] {
+					o, n := &old[r.OldEnd+i], &got[r.NewEnd+i]
+					if o.Type() != n.Type() || !bytes.Equal(o.Bytes(), n.Bytes()) || n.Offset() != o.Offset()+len(inserted)-deleted {
+						t.Fatalf("%s: %+v, but token %v after OldEnd became %v", desc, r, o, n)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Edits within a mode re-lex only the tokens they touch
+func TestIncrementalRange(t *testing.T) {
+	for _, c := range []struct {
+		input    string
+		offset   int
+		deleted  int
+		inserted string
+		want     lexer.TokenRange
+	}{
+		{"ab cd ef", 3, 2, "xy", lexer.TokenRange{Start: 1, OldEnd: 2, NewEnd: 2}},
+		{"ab cd ef", 3, 2, "x y", lexer.TokenRange{Start: 1, OldEnd: 2, NewEnd: 3}},
+		{"ab (cd (ef) gh) ij", 8, 2, "xyz", lexer.TokenRange{Start: 4, OldEnd: 5, NewEnd: 5}},
+		{"ab (cd) ef", 3, 1, "", lexer.TokenRange{Start: 1, OldEnd: 4, NewEnd: 3}},
+	} {
+		inc := lexer.NewIncremental(lexOuter, []byte(c.input))
+		if r := inc.Edit(c.offset, c.deleted, []byte(c.inserted)); r != c.want {
+			t.Errorf("Edit(%d, %d, %q) of %q = %+v, want %+v\n%s", c.offset, c.deleted, c.inserted, c.input, r, c.want, formatTokens(inc.Tokens()))
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package lexer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrInputCycle is returned by PushInput when the named input is already
+// being lexed
+var ErrInputCycle = errors.New("lexer: input cycle")
+
+// ErrInputDepth is returned by PushInput when the input stack is full
+var ErrInputDepth = errors.New("lexer: inputs nested too deeply")
+
+const defaultMaxInputDepth = 64
+
+// inputFrame saves the state of an input while a pushed input is lexed
+type inputFrame struct {
+	ioReader  io.Reader
+	reader    *bufio.Reader
+	input     []byte
+	fromBytes bool
+	noCopy    bool
+	bufLen    int
+	line      int
+	column    int
+	offset    int
+	filename  string
+	file      *File
+	base      int
+	prevRune  rune
+	state     StateFn // the state returned by the caller of PushInput(), resumed if EmitEOF() pops
+}
+
+// Lexer::PushInput
+func (l *lexer) PushInput(name string, reader io.Reader) error {
+	if l.tokenLen > 0 {
+		panic("illegal state: PushInput() called with a pending token")
+	}
+	if len(l.inputs) >= l.maxInputDepth {
+		return fmt.Errorf("%w: %s", ErrInputDepth, name)
+	}
+	if name != "" {
+		if name == l.filename {
+			return fmt.Errorf("%w: %s", ErrInputCycle, name)
+		}
+		for i := range l.inputs {
+			if name == l.inputs[i].filename {
+				return fmt.Errorf("%w: %s", ErrInputCycle, name)
+			}
+		}
+	}
+	l.inputs = append(l.inputs, inputFrame{
+		ioReader:  l.ioReader,
+		reader:    l.reader,
+		input:     l.input,
+		fromBytes: l.fromBytes,
+		noCopy:    l.noCopy,
+		bufLen:    l.bufLen,
+		line:      l.line,
+		column:    l.column,
+		offset:    l.offset,
+		filename:  l.filename,
+		file:      l.file,
+		base:      l.base,
+		prevRune:  l.prevRune,
+	})
+	// Outside of a state, the next state is known; otherwise step() records
+	// the state the caller returns
+	if !l.inState {
+		l.inputs[len(l.inputs)-1].state = l.state
+	}
+
+	l.ioReader = reader
+	l.input = nil
+	l.fromBytes = false
+	l.noCopy = false
+	l.bufLen = l.bufSize
+	if l.maxTokenSize > 0 && l.bufLen > l.maxTokenSize {
+		l.bufLen = l.maxTokenSize
+	}
+	l.reader = bufio.NewReaderSize(reader, l.bufLen)
+	l.line = 1
+	l.column = 0
+	l.offset = 0
+	l.filename = name
+	l.file = l.fset.AddFile(name)
+	l.base = 0
+	l.prevRune = 0
+
+	l.resetInput()
+	return nil
+}
+
+// popInput resumes lexing the input that was current before the last
+// PushInput(), returning the state recorded for it
+func (l *lexer) popInput() StateFn {
+	f := &l.inputs[len(l.inputs)-1]
+	l.ioReader = f.ioReader
+	l.reader = f.reader
+	l.input = f.input
+	l.fromBytes = f.fromBytes
+	l.noCopy = f.noCopy
+	l.bufLen = f.bufLen
+	l.line = f.line
+	l.column = f.column
+	l.offset = f.offset
+	l.filename = f.filename
+	l.file = f.file
+	l.base = f.base
+	l.prevRune = f.prevRune
+	state := f.state
+	l.inputs = l.inputs[:len(l.inputs)-1]
+
+	l.resetInput()
+	return state
+}
+
+// resetInput discards any runes peeked from the previous input
+func (l *lexer) resetInput() {
+	l.sequence++
+
+	l.pos = 0
+
+	l.tokenLen = 0
+
+	l.peekPos = 0
+
+	l.runes = l.runes[:0]
+
+	l.runeEnds = l.runeEnds[:0]
+
+	l.positions = l.positions[:0]
+
+	l.value = nil
+
+	l.updatePeekBytes()
+}
@@ -0,0 +1,62 @@
+package lexer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+var includes = map[string]string{
+	"file":  "a b",
+	"outer": "c @file d",
+}
+
+// lexMain emits words, handing '@' to lexInclude
+func lexMain(l lexer.Lexer) lexer.StateFn {
+	switch {
+	case l.MatchOneOrMoreRunes([]rune{' '}):
+		l.IgnoreToken()
+	case l.MatchOneRune('@'):
+		l.IgnoreToken()
+		return lexInclude
+	case l.NonMatchOneOrMoreRunes([]rune{' '}):
+		l.EmitTokenWithBytes(T_WORD)
+	default:
+		l.EmitEOF()
+		return nil
+	}
+	return lexMain
+}
+
+// lexInclude pushes the named input, then returns lexMain
+func lexInclude(l lexer.Lexer) lexer.StateFn {
+	l.NonMatchOneOrMoreRunes([]rune{' '})
+	name := string(l.PeekTokenBytes())
+	l.IgnoreToken()
+	if err := l.PushInput(name, strings.NewReader(includes[name])); err != nil {
+		l.EmitError(err.Error())
+	}
+	return lexMain
+}
+
+func TestPushInputResumesReturnedState(t *testing.T) {
+	for input, want := range map[string]string{
+		"x @file y z":     "x a b y z",
+		"x @outer y":      "x c a b d y",
+		"@file":           "a b",
+		"x @file @file y": "x a b a b y",
+	} {
+		lex := lexer.NewFromString(lexMain, input, 1)
+		var got []string
+		for tok := lex.NextToken(); !tok.EOF(); tok = lex.NextToken() {
+			got = append(got, string(tok.Bytes()))
+			if len(got) > 10 {
+				break
+			}
+		}
+		if strings.Join(got, " ") != want {
+			t.Errorf("%q: got %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package lexer
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Symbol is a small integer identifying a string interned by an Interner
+type Symbol int32
+
+// NoSymbol is the zero Symbol, which no string is interned as
+const NoSymbol Symbol = 0
+
+// InternMode selects the values an Interner attaches to tokens, see
+// WithInterner()
+type InternMode int
+
+const (
+	InternString InternMode = iota // the canonical string
+	InternSymbol                   // the Symbol
+)
+
+// InternerStats reports the size and use of an Interner
+type InternerStats struct {
+	Symbols int    // number of strings interned
+	Bytes   int    // total length of the strings interned
+	Lookups uint64 // number of lookups
+	Hits    uint64 // number of lookups that found an interned string
+}
+
+// Interner maps byte sequences to canonical strings and Symbols.  An Interner
+// is safe for use by multiple goroutines, so it can be shared by lexers
+type Interner struct {
+	lookups uint64 // first, for 64-bit alignment of atomic ops
+	hits    uint64
+
+	mu      sync.RWMutex
+	symbols map[string]Symbol
+	names   []string
+	bytes   int
+}
+
+// NewInterner returns a new, empty Interner
+func NewInterner() *Interner {
+	return &Interner{symbols: make(map[string]Symbol), names: []string{""}}
+}
+
+// Intern returns the canonical string for b
+func (in *Interner) Intern(b []byte) string {
+	_, name := in.lookup(b)
+	return name
+}
+
+// Symbol returns the Symbol for b
+func (in *Interner) Symbol(b []byte) Symbol {
+	sym, _ := in.lookup(b)
+	return sym
+}
+
+// Name returns the string interned as sym, or "" if there is none
+func (in *Interner) Name(sym Symbol) string {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if sym <= NoSymbol || int(sym) >= len(in.names) {
+		return ""
+	}
+	return in.names[sym]
+}
+
+// Len returns the number of strings interned
+func (in *Interner) Len() int {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return len(in.names) - 1
+}
+
+// Stats returns the size and use of the Interner
+func (in *Interner) Stats() InternerStats {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return InternerStats{
+		Symbols: len(in.names) - 1,
+		Bytes:   in.bytes,
+		Lookups: atomic.LoadUint64(&in.lookups),
+		Hits:    atomic.LoadUint64(&in.hits),
+	}
+}
+
+// lookup returns the Symbol and canonical string for b, interning it if
+// needed
+func (in *Interner) lookup(b []byte) (Symbol, string) {
+	atomic.AddUint64(&in.lookups, 1)
+	in.mu.RLock()
+	// Indexing by string(b) doesn't allocate
+	sym, ok := in.symbols[string(b)]
+	var name string
+	if ok {
+		name = in.names[sym]
+	}
+	in.mu.RUnlock()
+	if !ok {
+		in.mu.Lock()
+		defer in.mu.Unlock()
+		if sym, ok = in.symbols[string(b)]; !ok {
+			name = string(b)
+			sym = Symbol(len(in.names))
+			in.symbols[name] = sym
+			in.names = append(in.names, name)
+			in.bytes += len(name)
+			return sym, name
+		}
+		name = in.names[sym]
+	}
+	atomic.AddUint64(&in.hits, 1)
+	return sym, name
+}
+
+// intern returns the value for a token of type t with bytes b, or nil if
+// tokens of type t are not interned
+func (l *lexer) intern(t TokenType, b []byte) interface{} {
+	if !l.internTypes[t] {
+		return nil
+	}
+	sym, name := l.interner.lookup(b)
+	if l.internMode == InternSymbol {
+		return sym
+	}
+	return name
+}
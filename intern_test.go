@@ -0,0 +1,112 @@
+package lexer_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// An Interner gives each distinct string one canonical copy and Symbol, and
+// counts its lookups
+func TestInterner(t *testing.T) {
+	in := lexer.NewInterner()
+	b := []byte("ab")
+	if s := in.Intern(b); s != "ab" {
+		t.Errorf("Intern() = %q, want ab", s)
+	}
+	b[0] = 'x'
+	if got := in.Name(1); got != "ab" {
+		t.Errorf("Name(1) = %q after changing the interned bytes, want ab", got)
+	}
+	if sym := in.Symbol([]byte("cd")); sym != 2 {
+		t.Errorf("Symbol(cd) = %d, want 2", sym)
+	}
+	if sym := in.Symbol([]byte("ab")); sym != 1 {
+		t.Errorf("Symbol(ab) = %d, want 1", sym)
+	}
+	if s := in.Intern([]byte("cd")); s != "cd" {
+		t.Errorf("Intern(cd) = %q, want cd", s)
+	}
+	for _, sym := range []lexer.Symbol{lexer.NoSymbol, -1, 3} {
+		if got := in.Name(sym); got != "" {
+			t.Errorf("Name(%d) = %q, want none", sym, got)
+		}
+	}
+	if in.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", in.Len())
+	}
+	want := lexer.InternerStats{Symbols: 2, Bytes: 4, Lookups: 4, Hits: 2}
+	if got := in.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+// Tokens of the types interned get the canonical string or Symbol as their
+// value, and others none.  Lexers may share an Interner
+func TestWithInterner(t *testing.T) {
+	in := lexer.NewInterner()
+	for _, c := range []struct {
+		mode lexer.InternMode
+		want string
+	}{
+		{lexer.InternString, `WORD("b")="b" SPACE(" ") WORD("a")="a" SPACE(" ") WORD("b")="b" EOF`},
+		{lexer.InternSymbol, `WORD("b")=1 SPACE(" ") WORD("a")=2 SPACE(" ") WORD("b")=1 EOF`},
+		{lexer.InternString, `WORD("b")="b" SPACE(" ") WORD("a")="a" SPACE(" ") WORD("b")="b" EOF`},
+	} {
+		lex := lexer.NewWithOptions(lexSpaces, lexer.FromString("b a b"), lexer.WithInterner(in, c.mode, T_WORD))
+		var got []string
+		for _, tok := range allTokens(lex) {
+			got = append(got, strings.Split(tok.String(), "@")[0])
+		}
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("%v: got %s, want %s", c.mode, strings.Join(got, " "), c.want)
+		}
+	}
+	if got, want := in.Stats(), (lexer.InternerStats{Symbols: 2, Bytes: 2, Lookups: 9, Hits: 7}); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+// Lexers sharing an Interner concurrently agree on the Symbols, which are
+// counted once.  Run with -race
+func TestInternerConcurrent(t *testing.T) {
+	const lexers, words = 8, 200
+	var input strings.Builder
+	for i := 0; i < words; i++ {
+		fmt.Fprintf(&input, "w%d ", i%50)
+	}
+	in := lexer.NewInterner()
+	symbols := make([][]lexer.Symbol, lexers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range symbols {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			lex := lexer.NewWithOptions(lexWords, lexer.FromString(input.String()), lexer.WithInterner(in, lexer.InternSymbol, T_WORD))
+			for tok := lex.NextToken(); !tok.EOF(); tok = lex.NextToken() {
+				sym := tok.Value().(lexer.Symbol)
+				if name := in.Name(sym); name != string(tok.Bytes()) {
+					t.Errorf("Name(%d) = %q, want %q", sym, name, tok.Bytes())
+				}
+				symbols[i] = append(symbols[i], sym)
+				in.Stats()
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	for i := range symbols {
+		if fmt.Sprint(symbols[i]) != fmt.Sprint(symbols[0]) {
+			t.Errorf("lexer %d got symbols %v, but lexer 0 got %v", i, symbols[i], symbols[0])
+		}
+	}
+	want := lexer.InternerStats{Symbols: 50, Bytes: 140, Lookups: lexers * words, Hits: lexers*words - 50}
+	if got := in.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
@@ -1,9 +1,8 @@
 package lexer
 
 import (
-	"bytes"
+	"errors"
 	"io"
-	"strings"
 )
 
 // TokenType identifies the type of lex tokens.
@@ -15,6 +14,19 @@ type Token struct {
 	bytes  []byte
 	line   int
 	column int
+	offset int
+	end    int
+	pos    Pos
+	extra  *tokenExtra // nil unless the token has trivia or a value
+}
+
+// tokenExtra holds the parts of a token that most tokens don't have, so that
+// tokens stay small to copy.  It is shared by copies of the token, so is never
+// modified once set, see Token.setExtra()
+type tokenExtra struct {
+	leading  []Token
+	trailing []Token
+	value    interface{}
 }
 
 // Type returns the TokenType of the token
@@ -26,12 +38,61 @@ func (t *Token) Bytes() []byte { return t.bytes }
 // EOF returns true if the TokenType == T_EOF
 func (t *Token) EOF() bool { return T_EOF == t.typ }
 
+// Value returns the value of the token, if any, such as the string decoded
+// by MatchQuoted(), or the value passed to EmitTokenWithValue()
+func (t *Token) Value() interface{} {
+	if t.extra == nil {
+		return nil
+	}
+	return t.extra.value
+}
+
 // Line returns the line number of the token
 func (t *Token) Line() int { return t.line }
 
 // Column returns the column number of the token
 func (t *Token) Column() int { return t.column }
 
+// Offset returns the byte offset of the start of the token, 0-based
+func (t *Token) Offset() int { return t.offset }
+
+// End returns the byte offset immediately following the token
+func (t *Token) End() int { return t.end }
+
+// Pos returns the position of the token, see Lexer.FileSet()
+func (t *Token) Pos() Pos { return t.pos }
+
+// LeadingTrivia returns the trivia preceding the token, see KeepTrivia()
+func (t *Token) LeadingTrivia() []Token {
+	if t.extra == nil {
+		return nil
+	}
+	return t.extra.leading
+}
+
+// TrailingTrivia returns the trivia following the token on the same line,
+// up to and including the end of the line, see KeepTrivia()
+func (t *Token) TrailingTrivia() []Token {
+	if t.extra == nil {
+		return nil
+	}
+	return t.extra.trailing
+}
+
+// setExtra replaces the token's trivia and value, leaving copies of the token
+// as they were.  The trivia are clipped so that appending to them copies them
+func (t *Token) setExtra(leading []Token, trailing []Token, value interface{}) {
+	if leading == nil && trailing == nil && value == nil {
+		t.extra = nil
+		return
+	}
+	t.extra = &tokenExtra{
+		leading:  leading[:len(leading):len(leading)],
+		trailing: trailing[:len(trailing):len(trailing)],
+		value:    value,
+	}
+}
+
 // TokenType representing Lexer Error
 const T_LEX_ERR TokenType = -2
 
@@ -44,6 +105,25 @@ const T_EOF TokenType = 0
 // Rune represending EOF
 const RuneEOF = -1
 
+// ErrTokenTooLong is emitted, as a T_LEX_ERR token, when a token doesn't fit
+// within the maximum buffer size
+var ErrTokenTooLong = errors.New("lexer: token too long")
+
+// TooLongAction determines what happens after ErrTokenTooLong is emitted
+type TooLongAction int
+
+const (
+	// TooLongAbort stops lexing, emitting EOF after the error
+	TooLongAbort TooLongAction = iota
+
+	// TooLongSkip discards the token, with a single ErrTokenTooLong, and
+	// re-enters the state that found it to be too long.  If the state next
+	// emits a token of the same type, or ignores input when it was ignoring
+	// the long token, that is taken to be the rest of the token and is
+	// discarded too.  Lines within the discarded bytes are counted as usual
+	TooLongSkip
+)
+
 // StateFn represents the state of the scanner as a function that returns the next state.
 type StateFn func(Lexer) StateFn
 
@@ -58,6 +138,9 @@ type Marker struct {
 	tokenLen int
 	line     int
 	column   int
+	prevRune rune
+	value    interface{}
+	valueEnd int
 }
 
 // lexer.Lexer helps you tokenize bytes
@@ -84,6 +167,13 @@ type Lexer interface {
 	// Column returns the current column number, 1-based
 	Column() int
 
+	// Filename returns the name of the current input, as set with the Filename
+	// option or PushInput()
+	Filename() string
+
+	// FileSet returns the FileSet used to resolve the Pos of tokens
+	FileSet() *FileSet
+
 	// PeekTokenBytes allows you to inspect the currently matched byte sequence
 	PeekTokenBytes() []byte
 
@@ -94,18 +184,69 @@ type Lexer interface {
 	// EmitTokenWithBytes emits a token along with all the consumed runes
 	EmitTokenWithBytes(TokenType)
 
+	// EmitTokenWithValue emits a token with a value, such as a parsed number,
+	// returned by Token.Value(), without the consumed runes
+	EmitTokenWithValue(TokenType, interface{})
+
 	// IgnoreToken ignores the consumed bytes without emitting any tokens
 	IgnoreToken()
 
+	// EmitTrivia ignores the consumed bytes as trivia of the specified type,
+	// such as a comment, see KeepTrivia()
+	EmitTrivia(TokenType)
+
 	// EmitEOF emits a token of type TokenEOF
 	EmitEOF()
 
 	// Emits token of type T_LEX_ERR with string as the token bytes
 	EmitError(string)
 
+	// PushInput suspends the current input and lexes the named reader until its
+	// EOF, then resumes.  It must be called between tokens.  If EmitEOF() ends
+	// the pushed input, the state returned by the state that called PushInput()
+	// is resumed
+	PushInput(string, io.Reader) error
+
+	// PushMode saves a state on the mode stack, for lexers with nested modes
+	// such as string interpolation.  Return PopMode() to resume it
+	PushMode(StateFn)
+
+	// PopMode removes and returns the state on top of the mode stack
+	PopMode() StateFn
+
+	// ModeDepth returns the number of states on the mode stack
+	ModeDepth() int
+
+	// Begin starts a transaction, which holds back the tokens emitted and the
+	// bytes consumed until Commit(), so that Rollback() can return to this
+	// point.  It must be called between tokens.  Transactions may be nested
+	Begin()
+
+	// Commit ends the innermost transaction.  Ending the outermost releases
+	// its tokens
+	Commit()
+
+	// Rollback ends the innermost transaction, discarding the tokens emitted
+	// since Begin() and returning to the input position, line, column and
+	// mode stack at that point.  The current state is not changed
+	Rollback()
+
+	// Snapshot captures the state of the lexer, including any tokens emitted
+	// but not yet read, so that lexing can later resume from this point.  It
+	// must be called between calls to NextToken(), and requires a byte array
+	// or an io.Seeker source
+	Snapshot() (*Snapshot, error)
+
+	// Restore resumes lexing from a snapshot
+	Restore(*Snapshot) error
+
 	// NextToken retrieves the next emmitted token from the input
 	NextToken() *Token
 
+	// NextTokenInto retrieves the next emitted token into the specified token,
+	// allowing a Token to be reused to avoid allocating one per token
+	NextTokenInto(*Token)
+
 	// Marker returns a marker that you can use to reset the lexer state later
 	Marker() *Marker
 
@@ -115,6 +256,9 @@ type Lexer interface {
 	// Reset resets the lexer state to the specified marker
 	Reset(*Marker)
 
+	// The ...Bytes matchers cache the set of each slice they are given, by its
+	// address and length, so a slice's bytes must not change once matched
+
 	// MatchZeroOrOneBytes consumes the next rune if it matches, always returning true
 	MatchZeroOrOneBytes([]byte) bool
 
@@ -202,26 +346,68 @@ type Lexer interface {
 	// NonMatchOneOrMoreFunc consumes a run of non-matching runes
 	NonMatchOneOrMoreFunc(MatchFn) bool
 
+	// MatchUntilString consumes runes up to the first occurrence of delim, and
+	// the delimiter too if includeDelim.  If delim isn't found, it consumes the
+	// rest of the input and returns false
+	MatchUntilString(string, bool) bool
+
+	// MatchCapture consumes a run of matching runes and returns them, such as
+	// a delimiter to find later with MatchUntilString
+	MatchCapture(MatchFn) string
+
+	// MatchBalanced consumes open, and everything up to the matching close,
+	// allowing nesting.  If there is no matching close, it consumes the rest of
+	// the input and emits a T_LEX_ERR at the unmatched open.  It returns true
+	// if it consumed a balanced span
+	MatchBalanced(open string, close string, opts BalanceOptions) bool
+
+	// MatchQuoted consumes a string literal delimited by quote, following the
+	// escapes and rules of spec.  If the literal is unterminated or has an
+	// invalid escape, it emits a T_LEX_ERR at the start of the literal or the
+	// escape.  It returns true if it consumed a valid literal
+	MatchQuoted(quote rune, spec EscapeSpec) bool
+
+	// MatchNumber consumes a numeric literal described by spec, returning its
+	// kind, or NumberNone if there is none.  If the literal is invalid or out of
+	// range, it emits a T_LEX_ERR and returns NumberNone
+	MatchNumber(spec NumberSpec) NumberKind
+
 	// MatchEOF tries to match the next rune against RuneEOF
 	MatchEOF() bool
 }
 
 // New returns a new Lexer object with an unlimited read-buffer
 func New(startState StateFn, reader io.Reader, channelCap int) Lexer {
-	return newLexer(startState, reader, defaultBufSize, true, channelCap)
+	return NewWithOptions(startState, FromReader(reader), ChannelCap(channelCap))
 }
 
-// NewSize returns a new Lexer object for the specified reader and read-buffer size
+// NewSize returns a new Lexer object for the specified reader and read-buffer size.
+// A token (plus any lookahead) longer than the buffer results in ErrTokenTooLong
+// and aborts lexing
 func NewSize(startState StateFn, reader io.Reader, readerBufLen int, channelCap int) Lexer {
-	return newLexer(startState, reader, readerBufLen, false, channelCap)
+	return NewWithOptions(startState, FromReader(reader), BufferSize(readerBufLen), MaxTokenSize(readerBufLen), ChannelCap(channelCap))
+}
+
+// NewLimit returns a new Lexer object with a read-buffer that expands as needed,
+// up to maxTokenSize bytes.  A token (plus any lookahead) longer than that results
+// in ErrTokenTooLong, after which lexing is aborted or continues as per action
+func NewLimit(startState StateFn, reader io.Reader, maxTokenSize int, action TooLongAction, channelCap int) Lexer {
+	return NewWithOptions(startState, FromReader(reader), MaxTokenSize(maxTokenSize), OnTokenTooLong(action), ChannelCap(channelCap))
 }
 
 // NewFromString returns a new Lexer object for the specified string
 func NewFromString(startState StateFn, input string, channelCap int) Lexer {
-	return newLexer(startState, strings.NewReader(input), len(input), false, channelCap)
+	return NewWithOptions(startState, FromString(input), ChannelCap(channelCap))
 }
 
 // NewFromBytes returns a new Lexer object for the specified byte array
 func NewFromBytes(startState StateFn, input []byte, channelCap int) Lexer {
-	return newLexer(startState, bytes.NewReader(input), len(input), false, channelCap)
+	return NewWithOptions(startState, FromBytes(input), ChannelCap(channelCap))
+}
+
+// NewFromBytesNoCopy returns a new Lexer object for the specified byte array,
+// where the bytes of emitted tokens are sub-slices of the input rather than
+// copies.  The input must not be modified while the tokens are in use
+func NewFromBytesNoCopy(startState StateFn, input []byte, channelCap int) Lexer {
+	return NewWithOptions(startState, FromBytesNoCopy(input), ChannelCap(channelCap))
 }
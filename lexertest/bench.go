@@ -0,0 +1,66 @@
+package lexertest
+
+import (
+	"bytes"
+	"testing"
+)
+
+import "github.com/iNamik/go_lexer"
+
+// benchBufSize is the read-buffer size used for NewSize benchmarks
+const benchBufSize = 4096
+
+// Benchmark lexes input with startState b.N times, streaming it through New,
+// reporting throughput in bytes per second.  Use it from a benchmark:
+//
+//	func BenchmarkLexer(b *testing.B) {
+//		lexertest.Benchmark(b, lexFunc, input)
+//	}
+func Benchmark(b *testing.B, startState lexer.StateFn, input []byte) {
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lex := lexer.New(startState, bytes.NewReader(input), 1)
+		for t := lex.NextToken(); !t.EOF(); t = lex.NextToken() {
+		}
+	}
+}
+
+// BenchmarkAllocs runs sub-benchmarks lexing input with each of New, NewSize
+// (with a 4K buffer, so no token may be longer), NewFromBytes and
+// NewFromBytesNoCopy, reading tokens with both NextToken and NextTokenInto,
+// and reporting allocations and throughput for each
+func BenchmarkAllocs(b *testing.B, startState lexer.StateFn, input []byte) {
+	constructors := []struct {
+		name string
+		new  func() lexer.Lexer
+	}{
+		{"New", func() lexer.Lexer { return lexer.New(startState, bytes.NewReader(input), 1) }},
+		{"NewSize", func() lexer.Lexer { return lexer.NewSize(startState, bytes.NewReader(input), benchBufSize, 1) }},
+		{"NewFromBytes", func() lexer.Lexer { return lexer.NewFromBytes(startState, input, 1) }},
+		{"NewFromBytesNoCopy", func() lexer.Lexer { return lexer.NewFromBytesNoCopy(startState, input, 1) }},
+	}
+	for _, c := range constructors {
+		c := c
+		b.Run(c.name+"/NextToken", func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				lex := c.new()
+				for t := lex.NextToken(); !t.EOF(); t = lex.NextToken() {
+				}
+			}
+		})
+		b.Run(c.name+"/NextTokenInto", func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			b.ReportAllocs()
+			var t lexer.Token
+			for i := 0; i < b.N; i++ {
+				lex := c.new()
+				for lex.NextTokenInto(&t); !t.EOF(); lex.NextTokenInto(&t) {
+				}
+			}
+		})
+	}
+}
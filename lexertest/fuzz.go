@@ -0,0 +1,146 @@
+package lexertest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+import "github.com/iNamik/go_lexer"
+
+// FuzzOptions controls the invariants enforced by Fuzz and Check
+type FuzzOptions struct {
+
+	// Seeds are added to the fuzzing corpus
+	Seeds [][]byte
+
+	// ChannelCap is the token channel capacity passed to the lexer, which
+	// limits the number of tokens a state may emit per call.  Default 1
+	ChannelCap int
+
+	// MaxSteps limits the number of state transitions allowed before the
+	// lexer is considered to be looping.  Default 64 per input byte, plus 64
+	MaxSteps int
+
+	// AllowGaps allows gaps between tokens, for lexers that ignore input,
+	// such as whitespace.  By default tokens must cover the input
+	AllowGaps bool
+}
+
+// Fuzz drives arbitrary inputs through startState using NewFromBytes,
+// failing if any input violates the invariants checked by Check.
+// Use it from a fuzz test:
+//
+//	func FuzzLexer(f *testing.F) {
+//		lexertest.Fuzz(f, lexFunc, lexertest.FuzzOptions{})
+//	}
+func Fuzz(f *testing.F, startState lexer.StateFn, opts FuzzOptions) {
+	for _, seed := range opts.Seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input []byte) {
+		if err := Check(startState, input, opts); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// Check runs startState over input and verifies that the lexer does not
+// panic, terminates, emits exactly one EOF, and emits tokens whose spans are
+// in order, do not overlap, and cover the input, unless opts.AllowGaps.  The
+// returned error includes the last few tokens leading up to the failure
+func Check(startState lexer.StateFn, input []byte, opts FuzzOptions) (err error) {
+	if opts.ChannelCap <= 0 {
+		opts.ChannelCap = 1
+	}
+	if opts.MaxSteps <= 0 {
+		opts.MaxSteps = 64*len(input) + 64
+	}
+
+	tr := &checkTracer{opts: opts}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = tr.failure(input, fmt.Errorf("%v", p))
+		}
+	}()
+
+	lex := lexer.Trace(lexer.NewFromBytes(startState, input, opts.ChannelCap), tr)
+
+	for t := lex.NextToken(); !t.EOF(); t = lex.NextToken() {
+	}
+
+	if tr.eofs != 1 {
+		return tr.failure(input, fmt.Errorf("emitted %d EOF tokens", tr.eofs))
+	}
+
+	if eof := tr.tokens[len(tr.tokens)-1]; eof.Offset() != len(input) {
+		return tr.failure(input, fmt.Errorf("EOF at offset %d, but input is %d bytes", eof.Offset(), len(input)))
+	}
+
+	return nil
+}
+
+// errTooManySteps reports a lexer that does not appear to terminate
+var errTooManySteps = errors.New("too many state transitions, lexer does not terminate")
+
+// checkTracer enforces invariants as tokens are emitted
+type checkTracer struct {
+	opts   FuzzOptions
+	tokens []*lexer.Token
+	state  string
+	steps  int
+	emits  int // tokens emitted by the current state
+	eofs   int
+	end    int
+}
+
+func (c *checkTracer) TraceState(name string) {
+	c.state = name
+	c.emits = 0
+	c.steps++
+	if c.steps > c.opts.MaxSteps {
+		panic(errTooManySteps)
+	}
+}
+
+func (c *checkTracer) TraceEmit(t *lexer.Token) {
+	c.tokens = append(c.tokens, t)
+	c.emits++
+	if c.emits > c.opts.ChannelCap {
+		panic(fmt.Sprintf("state emitted more than %d tokens in one call, which would block", c.opts.ChannelCap))
+	}
+	if c.eofs > 0 {
+		panic("token emitted after EOF")
+	}
+	if t.EOF() {
+		c.eofs++
+	}
+	switch {
+	case t.Offset() < c.end:
+		panic(fmt.Sprintf("token at offset %d overlaps previous token ending at %d", t.Offset(), c.end))
+	case t.Offset() > c.end && !c.opts.AllowGaps:
+		panic(fmt.Sprintf("gap between previous token ending at %d and token at offset %d", c.end, t.Offset()))
+	case t.End() < t.Offset():
+		panic(fmt.Sprintf("token ends at %d before it starts at %d", t.End(), t.Offset()))
+	}
+	c.end = t.End()
+}
+
+func (c *checkTracer) TraceIgnore(b []byte) {
+	if !c.opts.AllowGaps && len(b) > 0 {
+		panic(fmt.Sprintf("ignored %q at offset %d", b, c.end))
+	}
+}
+
+func (c *checkTracer) TraceNextRune(r rune, line int, column int) {}
+
+func (c *checkTracer) TraceBackup(n int, line int, column int) {}
+
+func (c *checkTracer) TraceReset(m *lexer.Marker) {}
+
+// failure wraps err with the input and the tokens leading up to the failure
+func (c *checkTracer) failure(input []byte, err error) error {
+	tokens := tail(c.tokens, goldenContext)
+	return fmt.Errorf("%v\nstate: %s\ninput: %q\nlast %d of %d tokens:\n%s", err, c.state, input, len(tokens), len(c.tokens), Format(tokens))
+}
@@ -0,0 +1,26 @@
+package lexertest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer/lexertest"
+)
+
+func TestCheckCoverage(t *testing.T) {
+	input := []byte(strings.Repeat("word ", 100))
+	if err := lexertest.Check(lexSpaces, input, lexertest.FuzzOptions{}); err != nil {
+		t.Errorf("lexer keeping spaces: %v", err)
+	}
+	if err := lexertest.Check(lexWords, input, lexertest.FuzzOptions{AllowGaps: true}); err != nil {
+		t.Errorf("lexer ignoring spaces, with AllowGaps: %v", err)
+	}
+	err := lexertest.Check(lexWords, input, lexertest.FuzzOptions{})
+	if err == nil {
+		t.Fatal("lexer ignoring spaces: got no error, want a gap")
+	}
+	// Only the tokens just before the failure are shown
+	if msg := err.Error(); !strings.Contains(msg, "ignored") || strings.Count(msg, "\n") > 10 {
+		t.Errorf("lexer ignoring spaces: got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Package lexertest provides helpers for testing lexers built with
+iNamik/go_lexer.
+
+Golden-file tests run a lexer over each testdata/*.in file and compare the
+resulting token stream, one Token.String() per line, against the matching
+*.golden file:
+
+	func TestLexer(t *testing.T) {
+		lexertest.Golden(t, lexFunc)
+	}
+
+Run 'go test -lexertest.update' to (re)write the golden files from the
+current output, or use GoldenWith() to control updates and the directory.
+*/
+package lexertest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+import "github.com/iNamik/go_lexer"
+
+// update is name-spaced so as not to clash with test packages' own -update
+var update = flag.Bool("lexertest.update", false, "update lexertest golden files")
+
+// GoldenOptions configures GoldenWith()
+type GoldenOptions struct {
+	Dir    string // directory of *.in and *.golden files.  Default "testdata"
+	Update bool   // (re)write the golden files rather than comparing them
+}
+
+// goldenContext is the number of tokens shown before a difference or failure
+const goldenContext = 3
+
+// Tokens runs startState over input, returning all tokens up to and
+// including EOF
+func Tokens(startState lexer.StateFn, input []byte) []*lexer.Token {
+	tokens, p := run(startState, input)
+	if p != nil {
+		panic(p)
+	}
+	return tokens
+}
+
+// Format returns the tokens formatted one per line, using Token.String()
+func Format(tokens []*lexer.Token) string {
+	var b bytes.Buffer
+	for _, t := range tokens {
+		b.WriteString(t.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Golden runs startState over each testdata/*.in file, comparing the token
+// stream against the matching testdata/*.golden file
+func Golden(t *testing.T, startState lexer.StateFn) {
+	GoldenWith(t, startState, GoldenOptions{Update: *update})
+}
+
+// GoldenDir runs startState over each *.in file in dir, comparing the token
+// stream against the matching *.golden file
+func GoldenDir(t *testing.T, startState lexer.StateFn, dir string) {
+	GoldenWith(t, startState, GoldenOptions{Dir: dir, Update: *update})
+}
+
+// GoldenWith runs startState over each *.in file in opts.Dir, comparing the
+// token stream against, or writing it to, the matching *.golden file
+func GoldenWith(t *testing.T, startState lexer.StateFn, opts GoldenOptions) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = "testdata"
+	}
+	inputs, err := filepath.Glob(filepath.Join(dir, "*.in"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatalf("no *.in files found in %s", dir)
+	}
+	for _, in := range inputs {
+		in := in
+		name := strings.TrimSuffix(filepath.Base(in), ".in")
+		t.Run(name, func(t *testing.T) {
+			golden(t, startState, in, strings.TrimSuffix(in, ".in")+".golden", opts.Update)
+		})
+	}
+}
+
+// golden checks a single input file against its golden file
+func golden(t *testing.T, startState lexer.StateFn, in string, out string, update bool) {
+	t.Helper()
+
+	input, err := os.ReadFile(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, p := run(startState, input)
+	if p != nil {
+		t.Fatalf("%s: lexer panicked after %d tokens: %v\n%s", in, len(tokens), p, Format(tail(tokens, goldenContext)))
+	}
+
+	got := Format(tokens)
+
+	if update {
+		if err := os.WriteFile(out, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("%v (run 'go test -lexertest.update' to create it)", err)
+	}
+
+	if diff := Diff(string(want), got); diff != "" {
+		t.Errorf("%s: token stream does not match %s\n%s", in, out, diff)
+	}
+}
+
+// Diff compares two formatted token streams, returning a description of the
+// first divergent token, or "" if they are the same
+func Diff(want string, got string) string {
+	if want == got {
+		return ""
+	}
+	w := splitLines(want)
+	g := splitLines(got)
+
+	i := 0
+	for i < len(w) && i < len(g) && w[i] == g[i] {
+		i++
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "first difference at token %d:\n", i+1)
+	start := i - goldenContext
+	if start < 0 {
+		start = 0
+	}
+	for _, line := range w[start:i] {
+		fmt.Fprintf(&b, "        %s\n", line)
+	}
+	if i < len(w) {
+		fmt.Fprintf(&b, "  want: %s\n", w[i])
+	} else {
+		fmt.Fprintf(&b, "  want: <end of stream>\n")
+	}
+	if i < len(g) {
+		fmt.Fprintf(&b, "  got:  %s\n", g[i])
+	} else {
+		fmt.Fprintf(&b, "  got:  <end of stream>\n")
+	}
+	fmt.Fprintf(&b, "(want %d tokens, got %d)", len(w), len(g))
+	return b.String()
+}
+
+// run lexes input, returning the tokens read and the value of any panic
+func run(startState lexer.StateFn, input []byte) (tokens []*lexer.Token, p interface{}) {
+	defer func() {
+		p = recover()
+	}()
+	lex := lexer.NewFromBytes(startState, input, 1)
+	for {
+		t := lex.NextToken()
+		tokens = append(tokens, t)
+		if t.EOF() {
+			return
+		}
+	}
+}
+
+// splitLines splits s into lines, ignoring a trailing newline
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(strings.Replace(s, "\r\n", "\n", -1), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// tail returns the last n tokens
+func tail(tokens []*lexer.Token, n int) []*lexer.Token {
+	if len(tokens) > n {
+		return tokens[len(tokens)-n:]
+	}
+	return tokens
+}
@@ -0,0 +1,29 @@
+package lexertest_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iNamik/go_lexer/lexertest"
+)
+
+// Test packages commonly define their own -update flag
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGoldenWith(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "words.in"), []byte("a bc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lexertest.GoldenWith(t, lexWords, lexertest.GoldenOptions{Dir: dir, Update: true})
+	got, err := os.ReadFile(filepath.Join(dir, "words.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := lexertest.Format(lexertest.Tokens(lexWords, []byte("a bc\n"))); string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	lexertest.GoldenWith(t, lexWords, lexertest.GoldenOptions{Dir: dir, Update: *update})
+}
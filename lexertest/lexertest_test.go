@@ -0,0 +1,42 @@
+package lexertest_test
+
+import "github.com/iNamik/go_lexer"
+
+// Token types shared by the tests
+var (
+	T_WORD  = lexer.RegisterTokenType("WORD")
+	T_SPACE = lexer.RegisterTokenType("SPACE")
+)
+
+// blanks separate words
+var blanks = []byte(" \t\r\n")
+
+// words returns a lexer of runs of blanks, which are passed to blank, and
+// runs of anything else, which are emitted as T_WORD
+func words(blank func(l lexer.Lexer)) lexer.StateFn {
+	var state lexer.StateFn
+	state = func(l lexer.Lexer) lexer.StateFn {
+		switch {
+		case l.MatchEOF():
+			l.EmitEOF()
+			return nil
+		case l.MatchOneOrMoreBytes(blanks):
+			blank(l)
+		default:
+			l.NonMatchOneOrMoreBytes(blanks)
+			l.EmitTokenWithBytes(T_WORD)
+		}
+		return state
+	}
+	return state
+}
+
+var (
+	// lexWords emits words, and blanks as T_SPACE trivia, which is dropped
+	// unless the lexer keeps trivia
+	lexWords = words(func(l lexer.Lexer) { l.EmitTrivia(T_SPACE) })
+
+	// lexSpaces emits words, and blanks as T_SPACE tokens, so that its
+	// tokens cover the input
+	lexSpaces = words(func(l lexer.Lexer) { l.EmitTokenWithBytes(T_SPACE) })
+)
@@ -0,0 +1,22 @@
+package lexer
+
+// Lexer::PushMode
+func (l *lexer) PushMode(state StateFn) {
+	l.modes = append(l.modes, state)
+}
+
+// Lexer::PopMode
+func (l *lexer) PopMode() StateFn {
+	if len(l.modes) == 0 {
+		panic("Underflow Exception")
+	}
+	state := l.modes[len(l.modes)-1]
+	l.modes[len(l.modes)-1] = nil
+	l.modes = l.modes[:len(l.modes)-1]
+	return state
+}
+
+// Lexer::ModeDepth
+func (l *lexer) ModeDepth() int {
+	return len(l.modes)
+}
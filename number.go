@@ -0,0 +1,273 @@
+package lexer
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strconv"
+	"unicode"
+)
+
+// NumberKind is the lexical kind of a number, see MatchNumber()
+type NumberKind int
+
+const (
+	NumberNone NumberKind = iota
+	NumberDecimal
+	NumberHex
+	NumberOctal
+	NumberBinary
+	NumberFloat
+	NumberImaginary
+)
+
+var numberKindNames = [...]string{"none", "decimal", "hex", "octal", "binary", "float", "imaginary"}
+
+// String returns the name of the NumberKind
+func (k NumberKind) String() string {
+	if k >= 0 && int(k) < len(numberKindNames) {
+		return numberKindNames[k]
+	}
+	return "NumberKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// NumberSpec describes the numeric literals matched by MatchNumber()
+type NumberSpec struct {
+
+	// Hex enables 0x hex integers
+	Hex bool
+
+	// Octal enables 0o octal integers
+	Octal bool
+
+	// LegacyOctal makes integers with a leading 0 octal
+	LegacyOctal bool
+
+	// Binary enables 0b binary integers
+	Binary bool
+
+	// Float enables fractions and exponents
+	Float bool
+
+	// Imaginary enables an i suffix that makes any number imaginary, and an
+	// integer with a leading 0 decimal, as in Go
+	Imaginary bool
+
+	// Separator is the rune allowed between digits, such as '_', or 0 for none
+	Separator rune
+
+	// Suffixes lists the suffixes allowed after a number, such as "ul",
+	// matched without regard to case.  Suffixes are not part of the value
+	Suffixes []string
+
+	// Decode attaches the value to the next emitted token, see Token.Value():
+	// an int64 for integers, a float64 for floats, and a complex128 for
+	// imaginary numbers.  Values out of range are reported as errors
+	Decode bool
+
+	// BigInt decodes integers as a *big.Int, rather than an int64
+	BigInt bool
+}
+
+// GoNumbers describes Go numeric literals, except hex floats
+var GoNumbers = NumberSpec{
+	Hex:         true,
+	Octal:       true,
+	LegacyOctal: true,
+	Binary:      true,
+	Float:       true,
+	Imaginary:   true,
+	Separator:   '_',
+}
+
+// CNumbers describes C numeric literals, except hex floats
+var CNumbers = NumberSpec{
+	Hex:         true,
+	LegacyOctal: true,
+	Float:       true,
+	Suffixes:    []string{"u", "l", "ul", "lu", "ll", "ull", "llu", "f"},
+}
+
+// numberScan collects the digits of a number, and the first error in it
+type numberScan struct {
+	text   []byte
+	errMsg string
+	errAt  opener
+}
+
+// fail records an error, unless one has been already
+func (s *numberScan) fail(at opener, msg string) {
+	if s.errMsg == "" {
+		s.errMsg, s.errAt = msg, at
+	}
+}
+
+// Lexer::MatchNumber
+func (l *lexer) MatchNumber(spec NumberSpec) NumberKind {
+	r := l.PeekRune(0)
+	if !isDecimal(r) && !(spec.Float && r == '.' && isDecimal(l.PeekRune(1))) {
+		return NumberNone
+	}
+	start := l.opener()
+	var s numberScan
+	kind, base := NumberDecimal, 10
+	legacyOctal := false
+	if r == '0' {
+		switch p := unicode.ToLower(l.PeekRune(1)); {
+		case spec.Hex && p == 'x':
+			kind, base = NumberHex, 16
+		case spec.Octal && p == 'o':
+			kind, base = NumberOctal, 8
+		case spec.Binary && p == 'b':
+			kind, base = NumberBinary, 2
+		}
+	}
+	if base != 10 {
+		l.NextRune()
+		l.NextRune()
+		if l.scanDigits(&s, base, spec.Separator, true) == 0 {
+			s.fail(start, kind.String()+" literal has no digits")
+		}
+	} else {
+		n := l.scanDigits(&s, 10, spec.Separator, false)
+		if spec.Float && l.PeekRune(0) == '.' && l.PeekRune(1) != '.' {
+			kind = NumberFloat
+			s.text = append(s.text, '.')
+			l.NextRune()
+			l.scanDigits(&s, 10, spec.Separator, false)
+		}
+		if p := l.PeekRune(0); spec.Float && (p == 'e' || p == 'E') {
+			at := l.opener()
+			kind = NumberFloat
+			s.text = append(s.text, 'e')
+			l.NextRune()
+			if p = l.PeekRune(0); p == '+' || p == '-' {
+				s.text = append(s.text, byte(p))
+				l.NextRune()
+			}
+			if l.scanDigits(&s, 10, spec.Separator, false) == 0 {
+				s.fail(at, "exponent has no digits")
+			}
+		}
+		legacyOctal = kind == NumberDecimal && spec.LegacyOctal && n > 1 && s.text[0] == '0'
+	}
+	imaginary := spec.Imaginary && l.PeekRune(0) == 'i'
+	// As in Go, imaginary numbers are never legacy octal
+	if legacyOctal && !imaginary {
+		kind, base = NumberOctal, 8
+		if bytes.IndexAny(s.text, "89") >= 0 {
+			s.fail(start, "invalid digit in octal literal")
+		}
+	}
+	if imaginary {
+		l.NextRune()
+	} else {
+		l.matchSuffix(spec.Suffixes)
+	}
+	if s.errMsg == "" && spec.Decode {
+		var value interface{}
+		if value, s.errMsg = decodeNumber(s.text, kind, base, spec.BigInt, imaginary); s.errMsg != "" {
+			s.errAt = start
+		} else {
+			l.value, l.valueEnd = value, l.tokenLen
+		}
+	}
+	if s.errMsg != "" {
+		l.emitErrAt(s.errMsg, s.errAt.line, s.errAt.column, s.errAt.offset)
+		return NumberNone
+	}
+	if imaginary {
+		return NumberImaginary
+	}
+	return kind
+}
+
+// scanDigits consumes digits in base, and the separators between them,
+// returning the number of digits.  Decimal digits invalid in base are
+// consumed as errors.  afterPrefix allows a leading separator
+func (l *lexer) scanDigits(s *numberScan, base int, sep rune, afterPrefix bool) int {
+	n := 0
+	for {
+		r := l.PeekRune(0)
+		switch {
+		case digitVal(r) < base || isDecimal(r):
+			if digitVal(r) >= base {
+				name := "octal"
+				if base == 2 {
+					name = "binary"
+				}
+				s.fail(l.opener(), "invalid digit '"+string(r)+"' in "+name+" literal")
+			}
+			s.text = append(s.text, byte(r))
+			l.NextRune()
+			n++
+		case r == sep && sep != 0:
+			at := l.opener()
+			l.NextRune()
+			if p := l.PeekRune(0); n == 0 && !afterPrefix || digitVal(p) >= base && !isDecimal(p) {
+				s.fail(at, "'"+string(sep)+"' must separate successive digits")
+			}
+		default:
+			return n
+		}
+	}
+}
+
+// matchSuffix consumes the longest of suffixes that is next, if any
+func (l *lexer) matchSuffix(suffixes []string) {
+	best := 0
+	for _, suffix := range suffixes {
+		n := 0
+		for _, r := range suffix {
+			if unicode.ToLower(l.PeekRune(n)) != unicode.ToLower(r) {
+				n = 0
+				break
+			}
+			n++
+		}
+		if n > best {
+			best = n
+		}
+	}
+	for ; best > 0; best-- {
+		l.NextRune()
+	}
+}
+
+// decodeNumber returns the value of the digits in text, or a message if it
+// is out of range
+func decodeNumber(text []byte, kind NumberKind, base int, bigInt bool, imaginary bool) (interface{}, string) {
+	switch {
+	case imaginary:
+		value, msg := decodeNumber(text, kind, base, true, false)
+		switch v := value.(type) {
+		case float64:
+			return complex(0, v), ""
+		case *big.Int:
+			if f, _ := new(big.Float).SetInt(v).Float64(); !math.IsInf(f, 0) {
+				return complex(0, f), ""
+			}
+			return nil, "float overflow"
+		}
+		return nil, msg
+	case kind == NumberFloat:
+		f, err := strconv.ParseFloat(string(text), 64)
+		if err != nil && math.IsInf(f, 0) {
+			return nil, "float overflow"
+		}
+		return f, ""
+	case bigInt:
+		i, _ := new(big.Int).SetString(string(text), base)
+		return i, ""
+	}
+	i, err := strconv.ParseInt(string(text), base, 64)
+	if err != nil {
+		return nil, "integer overflow"
+	}
+	return i, ""
+}
+
+// isDecimal returns true if r is a decimal digit
+func isDecimal(r rune) bool {
+	return r >= '0' && r <= '9'
+}
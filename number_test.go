@@ -0,0 +1,96 @@
+package lexer_test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// lexNumber matches one number with spec, emitting it as T_NUM, and what
+// follows it as T_WORD
+func lexNumber(spec lexer.NumberSpec, kind *lexer.NumberKind) lexer.StateFn {
+	return func(l lexer.Lexer) lexer.StateFn {
+		if *kind = l.MatchNumber(spec); *kind != lexer.NumberNone {
+			l.EmitTokenWithBytes(T_NUM)
+		}
+		if l.MatchOneOrMoreFunc(func(rune) bool { return true }) {
+			l.EmitTokenWithBytes(T_WORD)
+		}
+		l.EmitEOF()
+		return nil
+	}
+}
+
+// MatchNumber consumes the longest number, returning its kind and, when
+// decoding, attaching its value, or emits an error at the offending rune
+func TestMatchNumber(t *testing.T) {
+	decodeC := lexer.CNumbers
+	decodeC.Decode = true
+	decodeBig := decodeNumbers
+	decodeBig.BigInt = true
+	big70, _ := new(big.Int).SetString("1180591620717411303424", 10)
+	for _, c := range []struct {
+		spec  lexer.NumberSpec
+		input string
+		kind  lexer.NumberKind
+		want  string // the tokens, less EOF
+	}{
+		{decodeNumbers, "x", lexer.NumberNone, `WORD("x")@1:1`},
+		{decodeNumbers, "42", lexer.NumberDecimal, `NUM("42")=42@1:1`},
+		{decodeNumbers, "1_000_000 ", lexer.NumberDecimal, `NUM("1_000_000")=1000000@1:1 WORD(" ")@1:10`},
+		{decodeNumbers, "0x1F", lexer.NumberHex, `NUM("0x1F")=31@1:1`},
+		{decodeNumbers, "0X_ff", lexer.NumberHex, `NUM("0X_ff")=255@1:1`},
+		{decodeNumbers, "0o17", lexer.NumberOctal, `NUM("0o17")=15@1:1`},
+		{decodeNumbers, "017", lexer.NumberOctal, `NUM("017")=15@1:1`},
+		{decodeNumbers, "0b101", lexer.NumberBinary, `NUM("0b101")=5@1:1`},
+		{decodeNumbers, "0", lexer.NumberDecimal, `NUM("0")=0@1:1`},
+		{decodeNumbers, "1.5", lexer.NumberFloat, `NUM("1.5")=1.5@1:1`},
+		{decodeNumbers, ".5", lexer.NumberFloat, `NUM(".5")=0.5@1:1`},
+		{decodeNumbers, "1.", lexer.NumberFloat, `NUM("1.")=1@1:1`},
+		{decodeNumbers, "1..2", lexer.NumberDecimal, `NUM("1")=1@1:1 WORD("..2")@1:2`},
+		{decodeNumbers, "1e3", lexer.NumberFloat, `NUM("1e3")=1000@1:1`},
+		{decodeNumbers, "2.5E-2", lexer.NumberFloat, `NUM("2.5E-2")=0.025@1:1`},
+		{decodeNumbers, "09.5", lexer.NumberFloat, `NUM("09.5")=9.5@1:1`},
+		{decodeNumbers, "3i", lexer.NumberImaginary, `NUM("3i")=(0+3i)@1:1`},
+		{decodeNumbers, "0x10i", lexer.NumberImaginary, `NUM("0x10i")=(0+16i)@1:1`},
+		{decodeNumbers, "1.5e1i", lexer.NumberImaginary, `NUM("1.5e1i")=(0+15i)@1:1`},
+		{decodeNumbers, "0129i", lexer.NumberImaginary, `NUM("0129i")=(0+129i)@1:1`},
+		{decodeNumbers, "1180591620717411303424i", lexer.NumberImaginary, `NUM("1180591620717411303424i")=(0+1.1805916207174113e+21i)@1:1`},
+		{decodeNumbers, "3I", lexer.NumberDecimal, `NUM("3")=3@1:1 WORD("I")@1:2`},
+		{decodeNumbers, "9223372036854775807", lexer.NumberDecimal, `NUM("9223372036854775807")=9223372036854775807@1:1`},
+		{decodeBig, "1180591620717411303424", lexer.NumberDecimal, `NUM("1180591620717411303424")=` + big70.String() + `@1:1`},
+		{decodeC, "10ul;", lexer.NumberDecimal, `NUM("10ul")=10@1:1 WORD(";")@1:5`},
+		{decodeC, "1.5F", lexer.NumberFloat, `NUM("1.5F")=1.5@1:1`},
+		{decodeC, "0b1", lexer.NumberDecimal, `NUM("0")=0@1:1 WORD("b1")@1:2`},
+		{decodeC, "1_0", lexer.NumberDecimal, `NUM("1")=1@1:1 WORD("_0")@1:2`},
+		{decodeC, "3i", lexer.NumberDecimal, `NUM("3")=3@1:1 WORD("i")@1:2`},
+
+		{decodeNumbers, "0b102", lexer.NumberNone, `LEX_ERR("invalid digit '2' in binary literal")@1:5`},
+		{decodeNumbers, "0b1021 ", lexer.NumberNone, `LEX_ERR("invalid digit '2' in binary literal")@1:5 WORD(" ")@1:7`},
+		{decodeNumbers, "0o78", lexer.NumberNone, `LEX_ERR("invalid digit '8' in octal literal")@1:4`},
+		{decodeNumbers, "0o7_8", lexer.NumberNone, `LEX_ERR("invalid digit '8' in octal literal")@1:5`},
+		{decodeNumbers, "0129", lexer.NumberNone, `LEX_ERR("invalid digit in octal literal")@1:1`},
+		{decodeNumbers, "0x", lexer.NumberNone, `LEX_ERR("hex literal has no digits")@1:1`},
+		{decodeNumbers, "0b_", lexer.NumberNone, `LEX_ERR("'_' must separate successive digits")@1:3`},
+		{decodeNumbers, "1__0", lexer.NumberNone, `LEX_ERR("'_' must separate successive digits")@1:2`},
+		{decodeNumbers, "1_", lexer.NumberNone, `LEX_ERR("'_' must separate successive digits")@1:2`},
+		{decodeNumbers, "1e+", lexer.NumberNone, `LEX_ERR("exponent has no digits")@1:2`},
+		{decodeNumbers, "x 1.5e", lexer.NumberNone, `WORD("x 1.5e")@1:1`},
+		{decodeNumbers, "9223372036854775808", lexer.NumberNone, `LEX_ERR("integer overflow")@1:1`},
+		{decodeNumbers, "1e999", lexer.NumberNone, `LEX_ERR("float overflow")@1:1`},
+		{decodeNumbers, "1e999i", lexer.NumberNone, `LEX_ERR("float overflow")@1:1`},
+		{decodeNumbers, "1" + strings.Repeat("0", 400) + "i", lexer.NumberNone, `LEX_ERR("float overflow")@1:1`},
+	} {
+		var kind lexer.NumberKind
+		lex := lexer.NewFromString(lexNumber(c.spec, &kind), c.input, 1)
+		var got []string
+		for tok := lex.NextToken(); !tok.EOF(); tok = lex.NextToken() {
+			got = append(got, tok.String())
+		}
+		if kind != c.kind || strings.Join(got, " ") != c.want {
+			t.Errorf("%q: got %v %s, want %v %s", c.input, kind, strings.Join(got, " "), c.kind, c.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package lexer
+
+import (
+	"io"
+)
+
+// Source identifies the input to be lexed.
+// Use FromReader, FromBytes, FromBytesNoCopy or FromString to create one
+type Source struct {
+	reader    io.Reader
+	input     []byte
+	fromBytes bool // input is used rather than reader, even if nil
+	noCopy    bool
+}
+
+// FromReader returns a Source that reads input from r
+func FromReader(r io.Reader) Source {
+	return Source{reader: r}
+}
+
+// FromBytes returns a Source for the specified byte array.
+// Token bytes are copies, independent of the input
+func FromBytes(input []byte) Source {
+	return Source{input: input, fromBytes: true}
+}
+
+// FromBytesNoCopy returns a Source for the specified byte array, where token
+// bytes are sub-slices of the input.  The input must not be modified while
+// the tokens are in use
+func FromBytesNoCopy(input []byte) Source {
+	return Source{input: input, fromBytes: true, noCopy: true}
+}
+
+// FromString returns a Source for the specified string
+func FromString(input string) Source {
+	return Source{input: []byte(input), fromBytes: true}
+}
+
+// NewlineMode determines how the lexer counts lines
+type NewlineMode int
+
+const (
+	// NewlineManual counts lines only when the lexer calls NewLine()
+	NewlineManual NewlineMode = iota
+
+	// NewlineLF starts a new line after each '\n'
+	NewlineLF
+
+	// NewlineAny starts a new line after each '\n', '\r' or "\r\n"
+	NewlineAny
+)
+
+// config holds the settings applied by Options
+type config struct {
+	bufSize       int
+	maxTokenSize  int
+	tooLongAction TooLongAction
+	channelCap    int
+	filename      string
+	fset          *FileSet
+	file          *File // shared by ParallelLex chunks, rather than adding one
+	base          int
+	line          int
+	column        int
+	tabWidth      int
+	newlines      NewlineMode
+	tracer        Tracer
+	maxInputDepth int
+	keepTrivia    bool
+	interner      *Interner
+	internMode    InternMode
+	internTypes   map[TokenType]bool
+}
+
+// Option configures a lexer created with NewWithOptions
+type Option func(*config)
+
+// BufferSize sets the initial size of the read-buffer used for a reader Source.
+// It panics if n is less than 1.  Default 1024
+func BufferSize(n int) Option {
+	if n < 1 {
+		panic("lexer: BufferSize must be at least 1")
+	}
+	return func(c *config) { c.bufSize = n }
+}
+
+// MaxTokenSize limits how large the read-buffer may grow.  A token (plus any
+// lookahead) longer than this results in ErrTokenTooLong.  Default 0, and any
+// n less than 1, is unlimited
+func MaxTokenSize(n int) Option {
+	return func(c *config) { c.maxTokenSize = n }
+}
+
+// OnTokenTooLong sets what happens after ErrTokenTooLong.  Default TooLongAbort
+func OnTokenTooLong(action TooLongAction) Option {
+	return func(c *config) { c.tooLongAction = action }
+}
+
+// ChannelCap sets the initial capacity of the queue of tokens emitted but not
+// yet read.  The queue grows as needed, so a state may emit any number of
+// tokens per call.  It panics if n is less than 1.  Default 1
+func ChannelCap(n int) Option {
+	if n < 1 {
+		panic("lexer: ChannelCap must be at least 1")
+	}
+	return func(c *config) { c.channelCap = n }
+}
+
+// Filename sets the name of the input, as returned by Lexer.Filename()
+func Filename(name string) Option {
+	return func(c *config) { c.filename = name }
+}
+
+// WithFileSet registers the input with fset, under the name set by Filename,
+// so that token positions can be resolved with fset.Position().  By default
+// each lexer has a FileSet of its own
+func WithFileSet(fset *FileSet) Option {
+	return func(c *config) { c.fset = fset }
+}
+
+// StartOffset sets the byte offset of the start of the input, for when it is
+// part of a larger file.  Token offsets include it.  Default 0
+func StartOffset(offset int) Option {
+	return func(c *config) { c.base = offset }
+}
+
+// StartLine sets the line number of the start of the input.  Default 1
+func StartLine(line int) Option {
+	return func(c *config) { c.line = line }
+}
+
+// StartColumn sets the column number of the start of the input.  Default 1
+func StartColumn(column int) Option {
+	return func(c *config) { c.column = column }
+}
+
+// TabWidth advances the column to the next multiple of n after each '\t',
+// rather than by one.  Default 0, tabs are one column
+func TabWidth(n int) Option {
+	return func(c *config) { c.tabWidth = n }
+}
+
+// Newlines sets how lines are counted.  Default NewlineManual
+func Newlines(mode NewlineMode) Option {
+	return func(c *config) { c.newlines = mode }
+}
+
+// MaxInputDepth limits how many inputs PushInput() may stack on top of the
+// original input.  Default 64
+func MaxInputDepth(n int) Option {
+	return func(c *config) { c.maxInputDepth = n }
+}
+
+// KeepTrivia keeps the bytes passed over by IgnoreToken() and EmitTrivia(),
+// attaching them to tokens as leading and trailing trivia.  Trivia from
+// IgnoreToken() has type T_UNKNOWN.  Default false
+func KeepTrivia() Option {
+	return func(c *config) { c.keepTrivia = true }
+}
+
+// WithInterner interns the bytes of tokens of the specified types, attaching
+// the canonical string or Symbol, per mode, as the token's Value().  Default
+// none
+func WithInterner(in *Interner, mode InternMode, types ...TokenType) Option {
+	return func(c *config) {
+		c.interner, c.internMode = in, mode
+		c.internTypes = make(map[TokenType]bool, len(types))
+		for _, t := range types {
+			c.internTypes[t] = true
+		}
+	}
+}
+
+// WithTracer attaches a Tracer to the lexer, see Trace()
+func WithTracer(tracer Tracer) Option {
+	return func(c *config) { c.tracer = tracer }
+}
+
+// NewWithOptions returns a new Lexer object for the specified source, configured
+// by the specified options
+func NewWithOptions(startState StateFn, src Source, opts ...Option) Lexer {
+	c := newConfig(opts)
+	return newLexer(startState, src, &c)
+}
+
+// newConfig returns the default config, with the specified options applied
+func newConfig(opts []Option) config {
+	c := config{
+		bufSize:       defaultBufSize,
+		channelCap:    1,
+		line:          1,
+		column:        1,
+		maxInputDepth: defaultMaxInputDepth,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
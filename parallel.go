@@ -0,0 +1,187 @@
+package lexer
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// ParallelOptions configures ParallelLex
+type ParallelOptions struct {
+
+	// Chunks is the number of chunks to split the input into, each lexed in
+	// a goroutine of its own.  Default runtime.GOMAXPROCS(0)
+	Chunks int
+
+	// Resync returns the index in data of the first byte at which lexing can
+	// safely start, or -1 if there is none.  data starts near a split point
+	// of the input.  Default returns the start of the first line in data
+	Resync func(data []byte) int
+
+	// Options are passed to the lexer for each chunk.  Chunks after the first
+	// start at column 1, and are offset to their position in the input
+	Options []Option
+}
+
+// resyncWindow is the number of bytes first read to look for a split point
+const resyncWindow = 4096
+
+// ParallelLex lexes size bytes of r with startState, splitting it into chunks
+// at safe boundaries and lexing them concurrently.  The tokens are returned
+// in order, ending with a single EOF, with line numbers and offsets relative
+// to the whole input.  The lexer must not depend on anything before a split
+// point, and each chunk must begin a line for columns to be correct
+func ParallelLex(startState StateFn, r io.ReaderAt, size int64, opts ParallelOptions) ([]Token, error) {
+	n := opts.Chunks
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	resync := opts.Resync
+	if resync == nil {
+		resync = resyncLine
+	}
+	bounds, err := splitChunks(r, size, n, resync)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newConfig(opts.Options)
+	if c.fset == nil {
+		c.fset = NewFileSet()
+	}
+	file := c.fset.addFile(c.filename, 0, c.line, c.column)
+
+	chunks := make([][]Token, len(bounds)-1)
+	errs := make([]error, len(bounds)-1)
+	panics := make([]interface{}, len(bounds)-1)
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Raise any panic in the caller, where it can be recovered
+			defer func() { panics[i] = recover() }()
+			chunkOpts := append([]Option(nil), opts.Options...)
+			chunkOpts = append(chunkOpts, WithFileSet(c.fset), StartOffset(int(bounds[i])), func(c *config) { c.file = file })
+			if i > 0 {
+				chunkOpts = append(chunkOpts, StartColumn(1))
+			}
+			chunks[i], errs[i] = lexChunk(startState, io.NewSectionReader(r, bounds[i], bounds[i+1]-bounds[i]), chunkOpts)
+		}(i)
+	}
+	wg.Wait()
+	for _, p := range panics {
+		if p != nil {
+			panic(p)
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Each chunk starts at the start line; move it to follow the previous one
+	var tokens []Token
+	var trivia []Token
+	lines := 0
+	for i, chunk := range chunks {
+		eof := chunk[len(chunk)-1]
+		if i < len(chunks)-1 {
+			chunk = chunk[:len(chunk)-1]
+		}
+		for _, t := range chunk {
+			addLines(&t, lines)
+			// Trivia leading the EOF of the previous chunk leads this token
+			if trivia != nil {
+				t.setExtra(append(trivia, t.LeadingTrivia()...), t.TrailingTrivia(), t.Value())
+				trivia = nil
+			}
+			tokens = append(tokens, t)
+		}
+		addLines(&eof, lines)
+		trivia = eof.LeadingTrivia()
+		lines = eof.line - c.line
+	}
+	return tokens, nil
+}
+
+// lexChunk returns the tokens of a chunk, up to and including EOF
+func lexChunk(startState StateFn, r io.Reader, opts []Option) (tokens []Token, err error) {
+	// Read errors surface as panics, any other panic is a bug
+	defer func() {
+		if p := recover(); p != nil {
+			e, ok := p.(readError)
+			if !ok {
+				panic(p)
+			}
+			err = e.err
+		}
+	}()
+	lex := NewWithOptions(startState, FromReader(r), opts...)
+	for {
+		var t Token
+		lex.NextTokenInto(&t)
+		tokens = append(tokens, t)
+		if t.EOF() {
+			return tokens, nil
+		}
+	}
+}
+
+// splitChunks returns the offsets of the boundaries between up to n chunks,
+// including 0 and size
+func splitChunks(r io.ReaderAt, size int64, n int, resync func([]byte) int) ([]int64, error) {
+	bounds := []int64{0}
+	for i := 1; i < n; i++ {
+		at := size * int64(i) / int64(n)
+		if at < bounds[len(bounds)-1] {
+			continue
+		}
+		// Read more until a boundary is found, or the end of the input
+		for window := int64(resyncWindow); ; window *= 2 {
+			if at+window > size {
+				window = size - at
+			}
+			data := make([]byte, window)
+			if _, err := r.ReadAt(data, at); err != nil && err != io.EOF {
+				return nil, err
+			}
+			if j := resync(data); j >= 0 {
+				if at+int64(j) > bounds[len(bounds)-1] && at+int64(j) < size {
+					bounds = append(bounds, at+int64(j))
+				}
+				break
+			}
+			if at+window >= size {
+				break
+			}
+		}
+	}
+	return append(bounds, size), nil
+}
+
+// resyncLine returns the index of the start of the first line in data
+func resyncLine(data []byte) int {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1
+	}
+	return -1
+}
+
+// addLines moves the token, and its trivia, down by n lines
+func addLines(t *Token, n int) {
+	t.line += n
+	if t.extra != nil {
+		leading := append([]Token(nil), t.extra.leading...)
+		trailing := append([]Token(nil), t.extra.trailing...)
+		for i := range leading {
+			addLines(&leading[i], n)
+		}
+		for i := range trailing {
+			addLines(&trailing[i], n)
+		}
+		t.setExtra(leading, trailing, t.extra.value)
+	}
+}
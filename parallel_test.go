@@ -0,0 +1,67 @@
+package lexer_test
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// failingReaderAt reads from r up to n bytes, then fails with err
+type failingReaderAt struct {
+	r   io.ReaderAt
+	n   int64
+	err error
+}
+
+func (f failingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= f.n {
+		return 0, f.err
+	}
+	if off+int64(len(p)) > f.n {
+		n, _ := f.r.ReadAt(p[:f.n-off], off)
+		return n, f.err
+	}
+	return f.r.ReadAt(p, off)
+}
+
+func TestParallelLexReadError(t *testing.T) {
+	input := strings.Repeat("word ", 1000)
+	errRead := errors.New("read failed")
+	r := failingReaderAt{strings.NewReader(input), 100, errRead}
+	tokens, err := lexer.ParallelLex(lexWords, r, int64(len(input)), lexer.ParallelOptions{Chunks: 1})
+	if err != errRead || tokens != nil {
+		t.Errorf("got %d tokens and error %v, want error %v", len(tokens), err, errRead)
+	}
+}
+
+// Panics other than read errors are bugs, raised rather than returned
+func TestParallelLexPanic(t *testing.T) {
+	input := strings.Repeat("word ", 1000)
+	panics := map[string]func(){
+		"error": func() { panic(errors.New("bug")) },
+		"runtime error": func() {
+			var words []string
+			_ = words[len(input)]
+		},
+	}
+	for name, f := range panics {
+		state := func(l lexer.Lexer) lexer.StateFn {
+			f()
+			return nil
+		}
+		func() {
+			defer func() {
+				p := recover()
+				if _, ok := p.(runtime.Error); name == "runtime error" && !ok || p == nil {
+					t.Errorf("%s: got panic %v", name, p)
+				}
+			}()
+			tokens, err := lexer.ParallelLex(state, strings.NewReader(input), int64(len(input)), lexer.ParallelOptions{Chunks: 2})
+			t.Errorf("%s: got %d tokens and error %v, want a panic", name, len(tokens), err)
+		}()
+	}
+}
@@ -0,0 +1,196 @@
+package lexer
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Pos is a compact encoding of a file and byte offset within a FileSet.
+// Use FileSet.Position() to resolve it to a file name, line and column
+type Pos int64
+
+// NoPos is the zero value for Pos, meaning no position is available
+const NoPos Pos = 0
+
+// posOffsetBits is the number of low bits of a Pos holding the offset,
+// allowing for files up to 1TB
+const posOffsetBits = 40
+
+// Position describes a resolved position within a named file
+type Position struct {
+	Filename string
+	Offset   int // byte offset, 0-based
+	Line     int // line number, 1-based
+	Column   int // column number in bytes, with tabs as set by TabWidth, 1-based
+}
+
+// IsValid returns true if the position is valid
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns the position as file:line:column, line:column if there is
+// no file name, or "-" if the position is invalid
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	s := strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+	if p.Filename != "" {
+		s = p.Filename + ":" + s
+	}
+	return s
+}
+
+// File records the start of each line within a single input, as reported
+// by the lexer, so that positions within it can be resolved
+type File struct {
+	name   string
+	index  int // 1-based index in the FileSet
+	base   int // offset of the first byte lexed
+	line   int // line number at base
+	column int // column number at base
+
+	mu    sync.Mutex
+	lines []int       // offset of the start of each line after the first, sorted
+	tabs  []tabColumn // column after each tab, when TabWidth is set, sorted
+}
+
+// tabColumn records the column of the byte after a tab
+type tabColumn struct {
+	offset int
+	column int
+}
+
+// Name returns the file name
+func (f *File) Name() string { return f.name }
+
+// Pos returns the Pos for the specified offset within the file
+func (f *File) Pos(offset int) Pos {
+	return Pos(int64(f.index)<<posOffsetBits | int64(offset))
+}
+
+// offset returns the offset of p within its file
+func (p Pos) offset() int { return int(p & (1<<posOffsetBits - 1)) }
+
+// AddLine records that a line starts at the specified offset.  Offsets may
+// be added in any order; duplicates are ignored
+func (f *File) AddLine(offset int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := len(f.lines)
+	if n == 0 || f.lines[n-1] < offset {
+		f.lines = append(f.lines, offset)
+		return
+	}
+	i := sort.SearchInts(f.lines, offset)
+	if f.lines[i] != offset {
+		f.lines = append(f.lines, 0)
+		copy(f.lines[i+1:], f.lines[i:])
+		f.lines[i] = offset
+	}
+}
+
+// addTab records that the byte at offset, after a tab, is at column.  Like
+// lines, tabs may be added in any order; duplicates are ignored
+func (f *File) addTab(offset int, column int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := len(f.tabs)
+	if n == 0 || f.tabs[n-1].offset < offset {
+		f.tabs = append(f.tabs, tabColumn{offset, column})
+		return
+	}
+	i := sort.Search(n, func(i int) bool { return f.tabs[i].offset >= offset })
+	if f.tabs[i].offset != offset {
+		f.tabs = append(f.tabs, tabColumn{})
+		copy(f.tabs[i+1:], f.tabs[i:])
+		f.tabs[i] = tabColumn{offset, column}
+	}
+}
+
+// Position resolves an offset within the file.  Columns agree with those of
+// tokens, counting tabs as set by TabWidth
+func (f *File) Position(offset int) Position {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Index of the first line starting after offset
+	i := sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset })
+	line, start, column := f.line, f.base, f.column
+	if i > 0 {
+		line, start, column = f.line+i, f.lines[i-1], 1
+	}
+	// Count from the last tab on the line before offset, if any
+	if j := sort.Search(len(f.tabs), func(j int) bool { return f.tabs[j].offset > offset }); j > 0 && f.tabs[j-1].offset > start {
+		start, column = f.tabs[j-1].offset, f.tabs[j-1].column
+	}
+	return Position{Filename: f.name, Offset: offset, Line: line, Column: column + offset - start}
+}
+
+// FileSet holds a set of files, allowing a Pos to be resolved to a Position.
+// A FileSet may be shared between lexers, and is safe for concurrent use
+type FileSet struct {
+	mu    sync.RWMutex
+	files []*File
+}
+
+// NewFileSet returns a new, empty FileSet
+func NewFileSet() *FileSet {
+	return &FileSet{}
+}
+
+// AddFile adds a new file to the set, starting at line 1, column 1
+func (s *FileSet) AddFile(name string) *File {
+	return s.addFile(name, 0, 1, 1)
+}
+
+// addFile adds a new file to the set, where offset base is at the specified
+// line and column
+func (s *FileSet) addFile(name string, base int, line int, column int) *File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := &File{name: name, index: len(s.files) + 1, base: base, line: line, column: column}
+	s.files = append(s.files, f)
+	return f
+}
+
+// copyFile adds a copy of f to the set under name, keeping the lines and tabs
+// up to offset end
+func (s *FileSet) copyFile(f *File, name string, end int) *File {
+	c := s.addFile(name, f.base, f.line, f.column)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, o := range f.lines {
+		if o <= end {
+			c.lines = append(c.lines, o)
+		}
+	}
+	for _, t := range f.tabs {
+		if t.offset <= end {
+			c.tabs = append(c.tabs, t)
+		}
+	}
+	return c
+}
+
+// File returns the file containing p, or nil if there is none
+func (s *FileSet) File(p Pos) *File {
+	i := int(p >> posOffsetBits)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if i < 1 || i > len(s.files) {
+		return nil
+	}
+	return s.files[i-1]
+}
+
+// Position resolves p to a file name, offset, line and column.  The result
+// is invalid if p is NoPos or does not belong to the set
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p.offset())
+	}
+	return Position{}
+}
@@ -0,0 +1,38 @@
+package lexer_test
+
+import (
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// A token's Pos must resolve to the token's own line and column, tabs and
+// all
+func TestPositionMatchesToken(t *testing.T) {
+	inputs := []string{
+		"\tx",
+		"a\tb\t\tc",
+		"ab\n\t\tcd ef\n\tg",
+		"日本\t語 x\t\n\t\n\ty",
+	}
+	for _, input := range inputs {
+		for _, opts := range [][]lexer.Option{
+			{lexer.TabWidth(8), lexer.Newlines(lexer.NewlineLF)},
+			{lexer.TabWidth(4), lexer.Newlines(lexer.NewlineLF), lexer.StartLine(3), lexer.StartColumn(5)},
+			{lexer.Newlines(lexer.NewlineLF)},
+		} {
+			fset := lexer.NewFileSet()
+			opts = append(opts, lexer.WithFileSet(fset))
+			lex := lexer.NewWithOptions(lexWords, lexer.FromString(input), opts...)
+			for tok := lex.NextToken(); ; tok = lex.NextToken() {
+				p := fset.Position(tok.Pos())
+				if p.Line != tok.Line() || p.Column != tok.Column() {
+					t.Errorf("%q: %v@%d:%d resolves to %v", input, tok, tok.Line(), tok.Column(), p)
+				}
+				if tok.EOF() {
+					break
+				}
+			}
+		}
+	}
+}
@@ -6,135 +6,500 @@ import (
 	"unicode/utf8"
 )
 import (
-	"github.com/iNamik/go_container/queue"
 	"github.com/iNamik/go_pkg/bufio/bleeder"
 )
 
 const defaultBufSize = 1024 //4096
 
+const runeBufSize = 64 // initial capacity of the rune buffer, grows as needed
+
 // lexer holds the state of the scanner.
 type lexer struct {
-	ioReader   io.Reader     // the original reader passed into New()
-	reader     *bufio.Reader // reader buffer
-	autoExpand bool          // should we auto-expand buffered reader?
-	bufLen     int           // reader buffer len
-	line       int           // current line in steram
-	column     int           // current column within current line
-	peekBytes  []byte        // cache of bufio.Reader.Peek()
-	peekPos    int
-	tokenLen   int
-	runes      queue.Interface // rune buffer
-	pos        int
-	sequence   int         // Incremented after each emit/ignore - used to validate markers
-	state      StateFn     // the next lexing function to enter
-	tokens     chan *Token // channel of scanned tokens.
-	eofToken   *Token
-	eof        bool
+	ioReader  io.Reader     // the original reader passed into New()
+	reader    *bufio.Reader // reader buffer, nil when lexing a byte array
+	input     []byte        // the byte array being lexed, nil when lexing a reader
+	fromBytes bool          // lexing input rather than a reader, even if input is nil
+	noCopy    bool          // should token bytes share input?
+	bufLen    int           // reader buffer len
+	line      int           // current line in steram
+	column    int           // current column within current line
+	peekBytes []byte        // cache of bufio.Reader.Peek()
+	peekPos   int
+	tokenLen  int
+	offset    int    // bytes consumed so far, for token offsets
+	runes     []rune // runes decoded from peekBytes for the current token
+	runeEnds  []int  // offset in peekBytes immediately following each rune
+	pos       int
+	sequence  int     // Incremented after each emit/ignore - used to validate markers
+	state     StateFn // the next lexing function to enter
+	tokens    []Token // tokens emitted but not yet read, from tokens[next]
+	next      int
+	eof       bool
+	tracer    Tracer // optional, see Trace()
+	filename  string
+	fset      *FileSet
+	file      *File // the input's entry in fset
+	base      int   // offset of the start of the input within the file
+	prevRune  rune  // the last rune consumed, for NewlineAny
+
+	tabWidth       int
+	newlines       NewlineMode
+	trackPositions bool      // are positions tracked per rune, for tabs and newlines?
+	positions      []runePos // the position before each rune of the current token
+
+	maxTokenSize  int           // limit on auto-expanding the buffer, 0 for none
+	tooLongAction TooLongAction // what to do when a token doesn't fit in the buffer
+	tooLong       bool          // set when a token was found not to fit in the buffer
+	skipped       bool          // set when a token that was too long was skipped
+	skipTail      bool          // set while the rest of a skipped token is dropped
+	skipType      TokenType     // the type of match the rest of a skipped token would be
+	aborted       bool          // set when lexing was aborted due to a token that was too long
+	running       StateFn       // the state currently running
+
+	bufSize       int          // initial reader buffer len, for pushed inputs
+	inputs        []inputFrame // stack of inputs suspended by PushInput()
+	maxInputDepth int
+	resume        StateFn // set when EmitEOF() pops an input, the state to resume with
+
+	keepTrivia bool    // are ignored bytes kept as trivia?
+	trivia     []Token // trivia for the next token
+	held       Token   // the last token, held for its trailing trivia
+	holding    bool
+
+	modes []StateFn // see PushMode()
+	reach int       // offset immediately following the furthest byte examined
+
+	sets    [8]cachedSet // the asciiSets of byte sets matched, see asciiSetOf()
+	nextSet int          // the entry in sets to replace next
+
+	seeker   io.ReadSeeker // the reader, if it can be repositioned by Restore()
+	seekBase int64         // reader position of the start of the input
+	inState  bool          // set while a state is running
+
+	txs      []transaction // see Begin()
+	txTokens []Token       // tokens emitted during transactions
+	txBytes  []byte        // bytes consumed from the reader during transactions
+
+	value    interface{} // value for the next token, see MatchQuoted()
+	valueEnd int         // tokenLen when value was set
+
+	interner    *Interner
+	internMode  InternMode
+	internTypes map[TokenType]bool
 }
 
 // newLexer
-func newLexer(startState StateFn, reader io.Reader, readerBufLen int, autoExpand bool, channelCap int) Lexer {
-	r := bufio.NewReaderSize(reader, readerBufLen)
+func newLexer(startState StateFn, src Source, c *config) *lexer {
 	l := &lexer{
-		ioReader:   reader,
-		reader:     r,
-		bufLen:     readerBufLen,
-		autoExpand: autoExpand,
-		runes:      queue.New(4), // 4 is just a nice number that seems appropriate
-		state:      startState,
-		tokens:     make(chan *Token, channelCap),
-		line:       1,
-		column:     0,
-		eofToken:   nil,
-		eof:        false,
+		ioReader:       src.reader,
+		input:          src.input,
+		fromBytes:      src.fromBytes,
+		noCopy:         src.noCopy,
+		bufLen:         c.bufSize,
+		bufSize:        c.bufSize,
+		maxInputDepth:  c.maxInputDepth,
+		keepTrivia:     c.keepTrivia,
+		maxTokenSize:   c.maxTokenSize,
+		tooLongAction:  c.tooLongAction,
+		filename:       c.filename,
+		fset:           c.fset,
+		base:           c.base,
+		tabWidth:       c.tabWidth,
+		newlines:       c.newlines,
+		trackPositions: c.tabWidth > 0 || c.newlines != NewlineManual,
+		tracer:         c.tracer,
+		interner:       c.interner,
+		internMode:     c.internMode,
+		internTypes:    c.internTypes,
+		runes:          make([]rune, 0, runeBufSize),
+		runeEnds:       make([]int, 0, runeBufSize),
+		state:          startState,
+		tokens:         make([]Token, 0, c.channelCap),
+		line:           c.line,
+		column:         c.column - 1,
+		eof:            false,
+	}
+	if !l.fromBytes {
+		if l.maxTokenSize > 0 && l.bufLen > l.maxTokenSize {
+			l.bufLen = l.maxTokenSize
+		}
+		if seeker, ok := l.ioReader.(io.ReadSeeker); ok {
+			var err error
+			if l.seekBase, err = seeker.Seek(0, io.SeekCurrent); err == nil {
+				l.seeker = seeker
+			}
+		}
+		l.reader = bufio.NewReaderSize(l.ioReader, l.bufLen)
+	}
+	if l.fset == nil {
+		l.fset = NewFileSet()
+	}
+	if l.file = c.file; l.file == nil {
+		l.file = l.fset.addFile(l.filename, l.base, c.line, c.column)
 	}
 	l.updatePeekBytes()
 	return l
 }
 
+// runePos records the lexer position before a rune is consumed, so that
+// BackupRunes can restore it
+type runePos struct {
+	line     int
+	column   int
+	prevRune rune
+}
+
+// receive reads the next token emitted into t, returning false if there is none
+func (l *lexer) receive(t *Token) bool {
+	if l.next == len(l.tokens) {
+		return false
+	}
+	*t = l.tokens[l.next]
+	l.next++
+	// Once all are read, reuse the queue from the start
+	if l.next == len(l.tokens) {
+		l.tokens = l.tokens[:0]
+		l.next = 0
+	}
+	return true
+}
+
+// step runs the next state
+func (l *lexer) step() {
+	if l.aborted && !l.eof {
+		l.eof = true
+		l.send(l.token(T_EOF, nil, l.line, l.column+1, l.offset))
+		return
+	}
+	if l.tracer != nil {
+		l.tracer.TraceState(stateName(l.state))
+	}
+	l.running = l.state
+	l.inState = true
+	depth := len(l.inputs)
+	l.state = l.state(l)
+	l.inState = false
+	// Inputs pushed by the state resume with the state it returned
+	for i := depth; i < len(l.inputs); i++ {
+		if l.inputs[i].state == nil {
+			l.inputs[i].state = l.state
+		}
+	}
+	if l.resume != nil {
+		l.state = l.resume
+		l.resume = nil
+	}
+	if l.tooLong {
+		l.tokenTooLong(noMatch)
+	}
+	// After skipping a token that was too long, re-enter the state that found it
+	if l.skipped {
+		l.skipped = false
+		l.state = l.running
+	}
+}
+
 // ensureRuneLen
 func (l *lexer) ensureRuneLen(n int) bool {
-	for l.runes.Len() < n {
-		// If auto-expand is enabled and
+	if l.tooLong || l.aborted {
+		return false
+	}
+	for len(l.runes) < n {
 		// If our peek buffer is full (suggesting we are likely not at eof) and
-		// If we don't have enough bytes left to safely decode a rune,
-		if l.autoExpand == true && len(l.peekBytes) == l.bufLen && (len(l.peekBytes)-l.peekPos) < utf8.UTFMax {
+		// If we don't have enough bytes left to safely decode a rune and
+		// If we haven't reached the maximum token size,
+		if l.bufferFull() && (len(l.peekBytes)-l.peekPos) < utf8.UTFMax && (l.maxTokenSize <= 0 || l.bufLen < l.maxTokenSize) {
 			l.bufLen *= 2
+			if l.maxTokenSize > 0 && l.bufLen > l.maxTokenSize {
+				l.bufLen = l.maxTokenSize
+			}
 			bl := bleeder.New(l.reader, l.ioReader)
 			l.reader = bufio.NewReaderSize(bl, l.bufLen)
 			l.updatePeekBytes()
 		}
-		rune, size := utf8.DecodeRune(l.peekBytes[l.peekPos:])
-		if utf8.RuneError == rune {
+		p := l.peekBytes[l.peekPos:]
+		// Running out of a full buffer means the token is too long, not EOF
+		if len(p) == 0 || (!utf8.FullRune(p) && l.bufferFull()) {
+			l.tooLong = l.bufferFull()
+			// Finding EOF examines the input beyond the last byte
+			l.reached(l.peekPos + 1)
 			return false
 		}
-		l.runes.Add(rune)
+		// Invalid UTF-8 decodes as utf8.RuneError, one byte at a time
+		r, size := utf8.DecodeRune(p)
 		l.peekPos += size
+		l.runes = append(l.runes, r)
+		l.runeEnds = append(l.runeEnds, l.peekPos)
 	}
+	l.reached(l.peekPos)
+
+	return true
+}
+
+// reached records that the input up to offset end in peekBytes was examined
+func (l *lexer) reached(end int) {
+	if l.offset+end > l.reach {
+		l.reach = l.offset + end
+	}
+}
+
+// advance consumes the rune at pos, which ends at offset end in peekBytes,
+// updating the line and column.  It is kept small enough to inline, as it is
+// called for every rune
+func (l *lexer) advance(r rune, end int) {
+	if l.trackPositions {
+		l.track(r, end)
+	} else {
+		l.column += end - l.tokenLen
+	}
+
+	l.pos++
 
-	return l.runes.Len() >= n
+	l.tokenLen = end
+}
+
+// track records the position before the rune at pos, which ends at offset end
+// in peekBytes, so that it can be backed up, and updates the line and column
+// for newlines and tabs
+func (l *lexer) track(r rune, end int) {
+	l.positions = append(l.positions[:l.pos], runePos{line: l.line, column: l.column, prevRune: l.prevRune})
+	switch {
+	case r == '\n' && l.newlines == NewlineAny && l.prevRune == '\r':
+		// Second half of "\r\n", already counted
+	case r == '\n' && l.newlines != NewlineManual, r == '\r' && l.newlines == NewlineAny:
+		l.line++
+		l.column = 0
+		l.file.AddLine(l.base + l.offset + end)
+	case r == '\t' && l.tabWidth > 0:
+		l.column += l.tabWidth - l.column%l.tabWidth
+		l.file.addTab(l.base+l.offset+end, l.column+1)
+	default:
+		l.column += end - l.tokenLen
+	}
+	l.prevRune = r
+}
+
+// tokenStart returns the line and column of the start of the current token
+func (l *lexer) tokenStart() (line int, column int) {
+	if l.trackPositions && l.pos > 0 {
+		return l.positions[0].line, l.positions[0].column + 1
+	}
+	return l.line, l.column - (l.tokenLen - 1)
 }
 
 // emit
 func (l *lexer) emit(t TokenType, emitBytes bool) {
-	if T_EOF == t {
+	if l.overflowed(t) {
+		return
+	}
+	if T_EOF == t && len(l.inputs) > 0 {
+		// EOF of a pushed input; discard the token and resume the previous input
+		l.consume(false)
+		l.resume = l.popInput()
+	} else if T_EOF == t {
 		if l.eof {
 			panic("illegal state: EmitEOF() already called")
 		}
 		l.consume(false)
-		l.eofToken = &Token{typ: T_EOF, bytes: nil, line: l.line, column: l.column + 1}
 		l.eof = true
-		l.tokens <- l.eofToken
+		l.send(l.token(T_EOF, nil, l.line, l.column+1, l.offset))
 	} else {
-		line := l.line
+		line, column := l.tokenStart()
+
+		offset := l.offset
 
-		column := l.column - (l.tokenLen - 1)
+		value := l.value
+
+		if value == nil && l.interner != nil {
+			value = l.intern(t, l.peekBytes[:l.tokenLen])
+		}
 
 		b := l.consume(emitBytes)
 
-		l.tokens <- &Token{typ: t, bytes: b, line: line, column: column}
+		token := l.token(t, b, line, column, offset)
+
+		if value != nil {
+			token.setExtra(nil, nil, value)
+		}
+
+		l.send(token)
 	}
 }
 
 // emitErr
 func (l *lexer) emitErr(err string) {
-	line := l.line
+	if l.overflowed(T_LEX_ERR) {
+		return
+	}
+
+	line, column := l.tokenStart()
 
-	column := l.column - (l.tokenLen - 1)
+	offset := l.offset
 
 	l.consume(false)
 
-	l.tokens <- &Token{typ: T_LEX_ERR, bytes: []byte(err), line: line, column: column}
+	l.send(l.token(T_LEX_ERR, []byte(err), line, column, offset))
+}
+
+// token returns a token starting at offset and ending at the current offset,
+// both relative to the start of the input.  It is inlined, so the token stays
+// on the stack unless kept
+func (l *lexer) token(t TokenType, b []byte, line int, column int, offset int) *Token {
+	return &Token{typ: t, bytes: b, line: line, column: column, offset: l.base + offset, end: l.base + l.offset, pos: l.file.Pos(l.base + offset)}
+}
+
+// emitErrAt emits an error spanning the current token, with the line,
+// column and Pos of the offset at within it
+func (l *lexer) emitErrAt(err string, line int, column int, at int) {
+	if l.overflowed(T_LEX_ERR) {
+		return
+	}
+
+	offset := l.offset
+
+	l.consume(false)
+
+	token := l.token(T_LEX_ERR, []byte(err), line, column, offset)
+
+	token.pos = l.file.Pos(l.base + at)
+
+	l.send(token)
+}
+
+// send
+func (l *lexer) send(token *Token) {
+	if l.keepTrivia {
+		l.sendWithTrivia(token)
+		return
+	}
+	l.deliver(token)
+}
+
+// deliver
+func (l *lexer) deliver(token *Token) {
+	if len(l.txs) > 0 {
+		l.txTokens = append(l.txTokens, *token)
+		return
+	}
+	if l.tracer != nil {
+		traced := *token // copy, so token itself doesn't escape
+		l.tracer.TraceEmit(&traced)
+	}
+	l.tokens = append(l.tokens, *token)
+}
+
+// Types of match, besides TokenTypes, passed to overflowed() and
+// tokenTooLong()
+const (
+	ignoredMatch TokenType = T_LEX_ERR - 1 - iota // ignored by IgnoreToken()
+	noMatch                                       // neither emitted nor ignored
+)
+
+// overflowed handles a token found to be too long for the buffer, returning
+// true if the caller should drop the match of type t that it was about to
+// emit or ignore
+func (l *lexer) overflowed(t TokenType) bool {
+	if l.aborted && !l.eof {
+		return true
+	}
+	if l.tooLong {
+		l.tokenTooLong(t)
+		return true
+	}
+	// The first match after a skip is the rest of the token that was too
+	// long if it is of the same type, else the token ended with the buffer
+	if l.skipTail {
+		l.skipTail = false
+		if l.tokenLen > 0 && t == l.skipType {
+			l.consume(false)
+			return true
+		}
+	}
+	return false
+}
+
+// tokenTooLong emits ErrTokenTooLong, discarding the contents of the buffer,
+// then either aborts or skips as configured.  t is the type of match that
+// was too long
+func (l *lexer) tokenTooLong(t TokenType) {
+	l.tooLong = false
+
+	line, column := l.tokenStart()
+
+	offset := l.offset
+
+	// Discard the whole buffer, which guarantees progress, counting its lines
+	// and columns as if its runes had been consumed
+	for l.tokenLen < len(l.peekBytes) {
+		r, size := utf8.DecodeRune(l.peekBytes[l.tokenLen:])
+		l.advance(r, l.tokenLen+size)
+	}
+
+	l.consume(false)
+
+	// A token is reported once, however many buffers it spans
+	if !l.skipTail {
+		l.send(l.token(T_LEX_ERR, []byte(ErrTokenTooLong.Error()), line, column, offset))
+	}
+
+	if l.tooLongAction == TooLongSkip {
+		l.skipped = true
+		l.skipTail = true
+		l.skipType = t
+	} else {
+		l.aborted = true
+	}
 }
 
 // consume
 func (l *lexer) consume(keepBytes bool) []byte {
 	var b []byte
-	if keepBytes {
+	// Keep bytes consumed from the reader, in case of Rollback()
+	if !l.fromBytes && len(l.txs) > 0 {
+		l.txBytes = append(l.txBytes, l.peekBytes[:l.tokenLen]...)
+	}
+	if l.fromBytes {
+		if keepBytes {
+			end := l.offset + l.tokenLen
+			if l.noCopy {
+				b = l.input[l.offset:end:end]
+			} else {
+				b = make([]byte, l.tokenLen)
+				copy(b, l.input[l.offset:end])
+			}
+		}
+	} else if keepBytes {
 		b = make([]byte, l.tokenLen)
 		n, err := l.reader.Read(b)
 		if err != nil || n != l.tokenLen {
 			panic("Unexpected problem in bufio.Reader.Read(): " + err.Error())
 		}
 	} else {
-		// May be better to just grab string and ignore, or always emit bytes
-		for ; l.tokenLen > 0; l.tokenLen-- {
-			_, err := l.reader.ReadByte()
-			if err != nil {
-				panic("Unexpected problem in bufio.Reader.ReadByte(): " + err.Error())
-			}
+		_, err := l.reader.Discard(l.tokenLen)
+		if err != nil {
+			panic("Unexpected problem in bufio.Reader.Discard(): " + err.Error())
 		}
-		b = nil
 	}
 	l.sequence++
 
+	l.offset += l.tokenLen
+
+	// Skip the write barrier for the common case
+	if l.value != nil {
+		l.value = nil
+	}
+
 	l.pos = 0
 
 	l.tokenLen = 0
 
 	l.peekPos = 0
 
-	l.runes.Clear()
+	l.runes = l.runes[:0]
+
+	l.runeEnds = l.runeEnds[:0]
+
+	l.positions = l.positions[:0]
 
 	l.updatePeekBytes()
 
@@ -143,9 +508,30 @@ func (l *lexer) consume(keepBytes bool) []byte {
 
 // updatePeekBytes
 func (l *lexer) updatePeekBytes() {
+	if l.fromBytes {
+		l.peekBytes = l.input[l.offset:]
+		return
+	}
 	var err error
 	l.peekBytes, err = l.reader.Peek(l.bufLen)
 	if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
-		panic(err)
+		panic(readError{err})
 	}
 }
+
+// readError is the panic raised by an error reading the input, which
+// distinguishes it from any other panic
+type readError struct {
+	err error
+}
+
+func (e readError) Error() string { return e.err.Error() }
+
+// Unwrap returns the error from the reader
+func (e readError) Unwrap() error { return e.err }
+
+// bufferFull returns true if the peek buffer is full, suggesting we are
+// likely not at eof
+func (l *lexer) bufferFull() bool {
+	return !l.fromBytes && len(l.peekBytes) == l.bufLen
+}
@@ -0,0 +1,262 @@
+package lexer
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// EscapeSpec describes the escapes and quoting rules of a quoted string,
+// see MatchQuoted()
+type EscapeSpec struct {
+
+	// Escape is the rune that starts an escape sequence, or 0 for none
+	Escape rune
+
+	// Simple maps the rune following Escape to the rune it stands for
+	Simple map[rune]rune
+
+	// Octal is the maximum number of digits in an octal escape, or 0 for none
+	Octal int
+
+	// OctalExact requires octal escapes to have exactly Octal digits
+	OctalExact bool
+
+	// Hex is the number of hex digits following x in a hex escape, -1 for one
+	// or more, or 0 for none
+	Hex int
+
+	// Unicode enables \uhhhh and \Uhhhhhhhh escapes
+	Unicode bool
+
+	// UTF16 enables \uhhhh escapes, combining surrogate pairs
+	UTF16 bool
+
+	// KeepUnknown keeps unknown escape sequences as-is, rather than
+	// reporting them
+	KeepUnknown bool
+
+	// Continuation removes escaped newlines
+	Continuation bool
+
+	// Doubled allows a doubled quote to stand for one quote
+	Doubled bool
+
+	// Multiline allows newlines within the string
+	Multiline bool
+
+	// Decode attaches the decoded string to the next emitted token, see
+	// Token.Value()
+	Decode bool
+}
+
+// GoEscapes describes Go interpreted string literals
+var GoEscapes = EscapeSpec{
+	Escape:     '\\',
+	Simple:     map[rune]rune{'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v', '\\': '\\', '"': '"'},
+	Octal:      3,
+	OctalExact: true,
+	Hex:        2,
+	Unicode:    true,
+}
+
+// JSONEscapes describes JSON strings
+var JSONEscapes = EscapeSpec{
+	Escape: '\\',
+	Simple: map[rune]rune{'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', '/': '/', '\\': '\\', '"': '"'},
+	UTF16:  true,
+}
+
+// CEscapes describes C string and character literals
+var CEscapes = EscapeSpec{
+	Escape:  '\\',
+	Simple:  map[rune]rune{'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v', '\\': '\\', '\'': '\'', '"': '"', '?': '?'},
+	Octal:   3,
+	Hex:     -1,
+	Unicode: true,
+}
+
+// SQLEscapes describes SQL strings, in which a quote is escaped by doubling it
+var SQLEscapes = EscapeSpec{
+	Doubled:   true,
+	Multiline: true,
+}
+
+// ShellEscapes describes double-quoted shell strings
+var ShellEscapes = EscapeSpec{
+	Escape:       '\\',
+	Simple:       map[rune]rune{'$': '$', '`': '`', '\\': '\\', '"': '"'},
+	KeepUnknown:  true,
+	Continuation: true,
+	Multiline:    true,
+}
+
+// Lexer::MatchQuoted
+func (l *lexer) MatchQuoted(quote rune, spec EscapeSpec) bool {
+	if l.PeekRune(0) != quote {
+		return false
+	}
+	start := l.opener()
+	l.NextRune()
+	var (
+		value  []byte
+		errMsg string
+		errAt  opener
+	)
+	for {
+		at := l.opener()
+		r := l.PeekRune(0)
+		if r == RuneEOF || (r == '\n' || r == '\r') && !spec.Multiline {
+			l.emitErrAt("unterminated string", start.line, start.column, start.offset)
+			return false
+		}
+		l.NextRune()
+		switch {
+		case r == quote && spec.Doubled && l.PeekRune(0) == quote:
+			l.NextRune()
+			value = appendRune(value, r)
+		case r == quote:
+			if errMsg != "" {
+				l.emitErrAt(errMsg, errAt.line, errAt.column, errAt.offset)
+				return false
+			}
+			if spec.Decode {
+				l.value, l.valueEnd = string(value), l.tokenLen
+			}
+			return true
+		case r == spec.Escape && spec.Escape != 0:
+			var msg string
+			if value, msg = l.matchEscape(value, quote, &spec); msg != "" && errMsg == "" {
+				errMsg, errAt = msg, at
+			}
+		default:
+			value = appendRune(value, r)
+		}
+	}
+}
+
+// matchEscape consumes the rest of an escape sequence, appending its value,
+// and returning a message if it is invalid
+func (l *lexer) matchEscape(value []byte, quote rune, spec *EscapeSpec) ([]byte, string) {
+	r := l.PeekRune(0)
+	if s, ok := spec.Simple[r]; ok {
+		l.NextRune()
+		return appendRune(value, s), ""
+	}
+	switch {
+	case r == RuneEOF:
+		return value, ""
+	case spec.Continuation && r == '\n':
+		l.NextRune()
+		return value, ""
+	case spec.Continuation && r == '\r':
+		l.NextRune()
+		l.MatchOneRune('\n')
+		return value, ""
+	case spec.Octal > 0 && r >= '0' && r <= '7':
+		v, n := l.matchDigits(8, spec.Octal)
+		switch {
+		case spec.OctalExact && n < spec.Octal:
+			return value, "invalid octal escape"
+		case v > 255:
+			return value, "octal escape value > 255"
+		}
+		return append(value, byte(v)), ""
+	case spec.Hex != 0 && r == 'x':
+		l.NextRune()
+		v, n := l.matchDigits(16, spec.Hex)
+		switch {
+		case n == 0 || spec.Hex > 0 && n < spec.Hex:
+			return value, "invalid hex escape"
+		case v > 255:
+			return value, "hex escape value > 255"
+		}
+		return append(value, byte(v)), ""
+	case (spec.Unicode || spec.UTF16) && r == 'u' || spec.Unicode && r == 'U':
+		l.NextRune()
+		size := 4
+		if r == 'U' {
+			size = 8
+		}
+		v, n := l.matchDigits(16, size)
+		if n < size {
+			return value, "invalid Unicode escape"
+		}
+		c := rune(v)
+		if spec.UTF16 && utf16.IsSurrogate(c) {
+			return appendRune(value, l.matchSurrogate(c, spec.Escape)), ""
+		}
+		if v > utf8.MaxRune || !utf8.ValidRune(c) {
+			return value, "invalid Unicode code point"
+		}
+		return appendRune(value, c), ""
+	case spec.KeepUnknown:
+		// The escaped rune is matched as usual
+		return appendRune(value, spec.Escape), ""
+	}
+	// Leave the closing quote and newlines to report the string as a whole
+	if r != quote && r != '\n' && r != '\r' {
+		l.NextRune()
+	}
+	return value, "unknown escape sequence"
+}
+
+// matchSurrogate combines the surrogate c with a following \uhhhh escape,
+// returning utf8.RuneError if there is none
+func (l *lexer) matchSurrogate(c rune, escape rune) rune {
+	if l.PeekRune(0) != escape || l.PeekRune(1) != 'u' {
+		return utf8.RuneError
+	}
+	var v rune
+	for i := 2; i < 6; i++ {
+		d := digitVal(l.PeekRune(i))
+		if d >= 16 {
+			return utf8.RuneError
+		}
+		v = v<<4 | rune(d)
+	}
+	r := utf16.DecodeRune(c, v)
+	if r != utf8.RuneError {
+		for i := 0; i < 6; i++ {
+			l.NextRune()
+		}
+	}
+	return r
+}
+
+// matchDigits consumes up to max digits in base, or any number if max < 0,
+// returning their value and count.  Values that overflow are capped
+func (l *lexer) matchDigits(base int, max int) (uint64, int) {
+	var v uint64
+	n := 0
+	for ; n != max; n++ {
+		d := digitVal(l.PeekRune(0))
+		if d >= base {
+			break
+		}
+		l.NextRune()
+		if v < 1<<32 {
+			v = v*uint64(base) + uint64(d)
+		}
+	}
+	return v, n
+}
+
+// digitVal returns the value of the digit r, or 16 if it is not a hex digit
+func digitVal(r rune) int {
+	switch {
+	case r >= '0' && r <= '9':
+		return int(r - '0')
+	case r >= 'a' && r <= 'f':
+		return int(r - 'a' + 10)
+	case r >= 'A' && r <= 'F':
+		return int(r - 'A' + 10)
+	}
+	return 16
+}
+
+// appendRune appends the UTF-8 encoding of r to b
+func appendRune(b []byte, r rune) []byte {
+	var a [utf8.UTFMax]byte
+	n := utf8.EncodeRune(a[:], r)
+	return append(b, a[:n]...)
+}
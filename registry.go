@@ -0,0 +1,47 @@
+package lexer
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]StateFn)
+)
+
+// RegisterLexer makes a start state available by name, typically from the
+// init() function of the package that defines the lexer.
+// If RegisterLexer is called twice with the same name, or if startState is
+// nil, it panics
+func RegisterLexer(name string, startState StateFn) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if startState == nil {
+		panic("lexer: RegisterLexer start state is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("lexer: RegisterLexer called twice for lexer " + name)
+	}
+	registry[name] = startState
+}
+
+// LookupLexer returns the start state registered under name, if any
+func LookupLexer(name string) (StateFn, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	startState, ok := registry[name]
+	return startState, ok
+}
+
+// Lexers returns a sorted list of the names of the registered lexers
+func Lexers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,121 @@
+package lexer
+
+import (
+	"fmt"
+)
+
+// RoundTripErrorKind identifies how a token stream fails to reproduce its input
+type RoundTripErrorKind int
+
+const (
+	// RoundTripGap means input bytes are missing from the token stream, for
+	// instance bytes passed to EmitToken() or ignored without KeepTrivia
+	RoundTripGap RoundTripErrorKind = iota
+
+	// RoundTripOverlap means a token starts before the previous one ends
+	RoundTripOverlap
+
+	// RoundTripMismatch means token bytes differ from the input
+	RoundTripMismatch
+)
+
+// String returns the name of the kind
+func (k RoundTripErrorKind) String() string {
+	switch k {
+	case RoundTripGap:
+		return "gap"
+	case RoundTripOverlap:
+		return "overlap"
+	case RoundTripMismatch:
+		return "mismatch"
+	}
+	return fmt.Sprintf("RoundTripErrorKind(%d)", int(k))
+}
+
+// RoundTripError reports the first place a token stream fails to reproduce
+// its input
+type RoundTripError struct {
+	Kind   RoundTripErrorKind
+	Offset int   // offset of the first byte not reproduced
+	Token  Token // the token or trivia at which the failure was found
+}
+
+// Error returns a description of the failure
+func (e *RoundTripError) Error() string {
+	return fmt.Sprintf("lexer: round trip %s at offset %d, at %s", e.Kind, e.Offset, e.Token.String())
+}
+
+// RoundTrip lexes input with startState, keeping trivia, and verifies that the
+// tokens reproduce it byte-for-byte.  See VerifyRoundTrip
+func RoundTrip(startState StateFn, input []byte) error {
+	lex := NewWithOptions(startState, FromBytes(input), KeepTrivia())
+	var tokens []*Token
+	for {
+		t := lex.NextToken()
+		tokens = append(tokens, t)
+		if t.EOF() {
+			break
+		}
+	}
+	return VerifyRoundTrip(input, tokens)
+}
+
+// VerifyRoundTrip verifies that writing out each token's leading trivia,
+// bytes and trailing trivia, in order, reproduces input, returning a
+// *RoundTripError for the first gap, overlap or mismatch.  The bytes of
+// T_LEX_ERR tokens are messages, so are not considered part of the input.
+// The tokens must all come from the one input, lexed from offset 0
+func VerifyRoundTrip(input []byte, tokens []*Token) error {
+	at := 0
+	check := func(t *Token) error {
+		switch {
+		case t.offset > at:
+			return &RoundTripError{Kind: RoundTripGap, Offset: at, Token: *t}
+		case t.offset < at:
+			return &RoundTripError{Kind: RoundTripOverlap, Offset: t.offset, Token: *t}
+		case t.end > len(input):
+			return &RoundTripError{Kind: RoundTripMismatch, Offset: len(input), Token: *t}
+		}
+		b := t.bytes
+		if t.typ == T_LEX_ERR {
+			b = nil
+		}
+		if b == nil && t.end > t.offset {
+			return &RoundTripError{Kind: RoundTripGap, Offset: t.offset, Token: *t}
+		}
+		want := input[t.offset:t.end]
+		for i := 0; i < len(b) || i < len(want); i++ {
+			if i >= len(b) || i >= len(want) || b[i] != want[i] {
+				return &RoundTripError{Kind: RoundTripMismatch, Offset: t.offset + i, Token: *t}
+			}
+		}
+		at = t.end
+		return nil
+	}
+	var last *Token
+	for _, t := range tokens {
+		leading, trailing := t.LeadingTrivia(), t.TrailingTrivia()
+		for i := range leading {
+			if err := check(&leading[i]); err != nil {
+				return err
+			}
+		}
+		if err := check(t); err != nil {
+			return err
+		}
+		for i := range trailing {
+			if err := check(&trailing[i]); err != nil {
+				return err
+			}
+		}
+		last = t
+	}
+	if at < len(input) {
+		e := &RoundTripError{Kind: RoundTripGap, Offset: at}
+		if last != nil {
+			e.Token = *last
+		}
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package lexer_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// lexDropSpaces emits words, and blanks as T_SPACE tokens without their bytes
+var lexDropSpaces = words(func(l lexer.Lexer) { l.EmitToken(T_SPACE) })
+
+// pointers returns pointers to tokens, dropping and repeating them as listed
+// in order, or all of them if order is nil
+func pointers(tokens []lexer.Token, order []int) []*lexer.Token {
+	if order == nil {
+		for i := range tokens {
+			order = append(order, i)
+		}
+	}
+	var p []*lexer.Token
+	for _, i := range order {
+		p = append(p, &tokens[i])
+	}
+	return p
+}
+
+// VerifyRoundTrip reports the first gap, overlap or mismatch, and the token
+// at which it was found
+func TestVerifyRoundTrip(t *testing.T) {
+	tooLong := lexer.NewLimit(lexSpaces, strings.NewReader("ab "+strings.Repeat("x", 40)+" cd"), 32, lexer.TooLongSkip, 1)
+	for _, c := range []struct {
+		name   string
+		input  string
+		tokens []lexer.Token
+		order  []int
+		kind   lexer.RoundTripErrorKind
+		offset int
+		token  string // "" for no error
+	}{
+		{"lossless", "ab cd", allTokens(lexer.NewFromString(lexSpaces, "ab cd", 1)), nil, 0, 0, ""},
+		{"no tokens", "", nil, nil, 0, 0, ""},
+		{"dropped trivia", "ab cd", allTokens(lexer.NewFromString(lexWords, "ab cd", 1)), nil, lexer.RoundTripGap, 2, `WORD("cd")@1:4`},
+		{"no bytes", "ab cd", allTokens(lexer.NewFromString(lexDropSpaces, "ab cd", 1)), nil, lexer.RoundTripGap, 2, `SPACE@1:3`},
+		{"missing token", "ab cd", allTokens(lexer.NewFromString(lexSpaces, "ab cd", 1)), []int{0, 2, 3}, lexer.RoundTripGap, 2, `WORD("cd")@1:4`},
+		{"missing tail", "ab cd", allTokens(lexer.NewFromString(lexSpaces, "ab cd", 1)), []int{0, 1}, lexer.RoundTripGap, 3, `SPACE(" ")@1:3`},
+		{"no tokens for input", "ab", nil, nil, lexer.RoundTripGap, 0, `EOF@0:0`},
+		{"repeated token", "ab cd", allTokens(lexer.NewFromString(lexSpaces, "ab cd", 1)), []int{0, 1, 1, 2, 3}, lexer.RoundTripOverlap, 2, `SPACE(" ")@1:3`},
+		{"other input", "ab ce", allTokens(lexer.NewFromString(lexSpaces, "ab cd", 1)), nil, lexer.RoundTripMismatch, 4, `WORD("cd")@1:4`},
+		{"shorter input", "ab c", allTokens(lexer.NewFromString(lexSpaces, "ab cd", 1)), nil, lexer.RoundTripMismatch, 4, `WORD("cd")@1:4`},
+		{"lexer error", "ab " + strings.Repeat("x", 40) + " cd", allTokens(tooLong), nil, lexer.RoundTripGap, 3, `LEX_ERR("lexer: token too long")@1:4`},
+	} {
+		err := lexer.VerifyRoundTrip([]byte(c.input), pointers(c.tokens, c.order))
+		if c.token == "" {
+			if err != nil {
+				t.Errorf("%s: %v", c.name, err)
+			}
+			continue
+		}
+		e, ok := err.(*lexer.RoundTripError)
+		if !ok {
+			t.Errorf("%s: returned %v, want a *RoundTripError", c.name, err)
+			continue
+		}
+		if e.Kind != c.kind || e.Offset != c.offset || e.Token.String() != c.token {
+			t.Errorf("%s: returned %v %d %v, want %v %d %s", c.name, e.Kind, e.Offset, &e.Token, c.kind, c.offset, c.token)
+		}
+	}
+}
+
+// Lexing with trivia kept reproduces any input
+func TestRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		input := randomInput(rnd)
+		if err := lexer.RoundTrip(lexWords, input); err != nil {
+			t.Errorf("%q: %v", input, err)
+		}
+	}
+	err := lexer.RoundTrip(lexDropSpaces, []byte("ab cd"))
+	if want := `lexer: round trip gap at offset 2, at SPACE@1:3`; err == nil || err.Error() != want {
+		t.Errorf("RoundTrip() of dropped bytes returned %v, want %s", err, want)
+	}
+}
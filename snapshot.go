@@ -0,0 +1,135 @@
+package lexer
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// ErrNotSeekable is returned by Snapshot() and Restore() when the source is a
+// reader that does not implement io.Seeker
+var ErrNotSeekable = errors.New("lexer: source is not seekable")
+
+// ErrNotAtBoundary is returned by Snapshot() when called from a state, with a
+// token in progress, with inputs pushed by PushInput(), or during a transaction
+var ErrNotAtBoundary = errors.New("lexer: not at a token boundary")
+
+// Snapshot holds the state of a lexer between tokens, see Lexer.Snapshot()
+type Snapshot struct {
+	offset   int
+	line     int
+	column   int
+	file     *File // the input's lines up to offset
+	prevRune rune
+	state    StateFn
+	modes    []StateFn
+	pending  []Token // tokens emitted but not yet read
+	eof      bool
+	aborted  bool
+	trivia   []Token
+	held     Token
+	holding  bool
+}
+
+// Offset returns the offset of the next byte to be lexed
+func (s *Snapshot) Offset() int { return s.offset }
+
+// Line returns the line number at the snapshot
+func (s *Snapshot) Line() int { return s.line }
+
+// Lexer::Snapshot
+func (l *lexer) Snapshot() (*Snapshot, error) {
+	if !l.fromBytes && l.seeker == nil {
+		return nil, ErrNotSeekable
+	}
+	if l.inState || l.tokenLen > 0 || len(l.inputs) > 0 || len(l.txs) > 0 {
+		return nil, ErrNotAtBoundary
+	}
+	s := &Snapshot{
+		offset:   l.base + l.offset,
+		line:     l.line,
+		column:   l.column,
+		file:     l.file,
+		prevRune: l.prevRune,
+		state:    l.state,
+		modes:    append([]StateFn(nil), l.modes...),
+		eof:      l.eof,
+		aborted:  l.aborted,
+		trivia:   append([]Token(nil), l.trivia...),
+		held:     l.held,
+		holding:  l.holding,
+		pending:  append([]Token(nil), l.tokens[l.next:]...),
+	}
+	return s, nil
+}
+
+// Lexer::Restore
+func (l *lexer) Restore(s *Snapshot) error {
+	if !l.fromBytes && l.seeker == nil {
+		return ErrNotSeekable
+	}
+	if l.inState || len(l.inputs) > 0 || len(l.txs) > 0 {
+		return ErrNotAtBoundary
+	}
+	if !l.fromBytes {
+		if _, err := l.seeker.Seek(l.seekBase+int64(s.offset-l.base), io.SeekStart); err != nil {
+			return err
+		}
+		l.ioReader = l.seeker
+		l.reader = bufio.NewReaderSize(l.ioReader, l.bufLen)
+	}
+	l.offset = s.offset - l.base
+	l.line = s.line
+	l.column = s.column
+	l.prevRune = s.prevRune
+	l.state = s.state
+	l.modes = append(l.modes[:0], s.modes...)
+	l.eof = s.eof
+	l.aborted = s.aborted
+	l.tooLong = false
+	l.skipped = false
+	l.trivia = append([]Token(nil), s.trivia...)
+	l.held = s.held
+	l.holding = s.holding
+
+	l.tokens = append(l.tokens[:0], s.pending...)
+	l.next = 0
+
+	// A lexer restored from another's snapshot hasn't seen the lines before
+	// the offset, so copy them into a file of its own, and move the Pos of
+	// the tokens it holds there
+	if l.file != s.file {
+		l.file = l.fset.copyFile(s.file, l.filename, s.offset)
+		l.trivia = l.moveTokens(l.trivia)
+		l.held = l.moveTokens([]Token{l.held})[0]
+		l.tokens = l.moveTokens(l.tokens)
+	}
+
+	l.resetInput()
+	return nil
+}
+
+// movePos returns the Pos in l.file of the same offset as p
+func (l *lexer) movePos(p Pos) Pos {
+	if p == NoPos {
+		return NoPos
+	}
+	return l.file.Pos(p.offset())
+}
+
+// moveTokens returns a copy of tokens, with their Pos, and those of their
+// trivia, moved to l.file
+func (l *lexer) moveTokens(tokens []Token) []Token {
+	if tokens == nil {
+		return nil
+	}
+	moved := make([]Token, len(tokens))
+	for i, t := range tokens {
+		t.pos = l.movePos(t.pos)
+		if t.extra != nil {
+			t.setExtra(l.moveTokens(t.extra.leading), l.moveTokens(t.extra.trailing), t.extra.value)
+		}
+		moved[i] = t
+	}
+	return moved
+}
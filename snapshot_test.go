@@ -0,0 +1,108 @@
+package lexer_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// lexQuads emits up to four tokens per state, so that some are pending
+// whenever the lexer stops
+func lexQuads(l lexer.Lexer) lexer.StateFn {
+	for i := 0; i < 4; i++ {
+		if lexWords(l) == nil {
+			return nil
+		}
+	}
+	return lexQuads
+}
+
+// formatResolved formats tokens one per line, with their trivia and their
+// Pos resolved by fset
+func formatResolved(fset *lexer.FileSet, tokens []lexer.Token) string {
+	s := ""
+	for i := range tokens {
+		tok := &tokens[i]
+		s += fmt.Sprintf("%v %v", tok, fset.Position(tok.Pos()))
+		for _, trivia := range [][]lexer.Token{tok.LeadingTrivia(), tok.TrailingTrivia()} {
+			s += " ["
+			for j := range trivia {
+				s += fmt.Sprintf(" %v %v", &trivia[j], fset.Position(trivia[j].Pos()))
+			}
+			s += " ]"
+		}
+		s += "\n"
+	}
+	return s
+}
+
+// Restoring a snapshot, into the lexer it came from or a new one, must give
+// the tokens that followed it, with their trivia, resolving to the same
+// positions
+func TestSnapshotRestore(t *testing.T) {
+	inputs := []string{
+		"aa\nbb\ncc\ndd",
+		"a b\n\tc  d\t e\n\n\tf g h i j",
+	}
+	sources := map[string]func(input string) lexer.Source{
+		"bytes":  func(input string) lexer.Source { return lexer.FromString(input) },
+		"reader": func(input string) lexer.Source { return lexer.FromReader(strings.NewReader(input)) },
+	}
+	for _, input := range inputs {
+		for name, source := range sources {
+			for _, keep := range []bool{false, true} {
+				newLexer := func() (lexer.Lexer, *lexer.FileSet) {
+					fset := lexer.NewFileSet()
+					opts := []lexer.Option{lexer.Newlines(lexer.NewlineLF), lexer.TabWidth(4), lexer.WithFileSet(fset)}
+					if keep {
+						opts = append(opts, lexer.KeepTrivia())
+					}
+					return lexer.NewWithOptions(lexQuads, source(input), opts...), fset
+				}
+				lex, fset := newLexer()
+				all := allTokens(lex)
+				for n := 0; n < len(all); n++ {
+					want := formatResolved(fset, all[n:])
+
+					lex, fset := newLexer()
+					for i := 0; i < n; i++ {
+						lex.NextToken()
+					}
+					snap, err := lex.Snapshot()
+					if err != nil {
+						t.Fatalf("%q %s: Snapshot() after %d tokens: %v", input, name, n, err)
+					}
+					if got := formatResolved(fset, allTokens(lex)); got != want {
+						t.Errorf("%q %s %v: after Snapshot() at %d got\n%swant\n%s", input, name, keep, n, got, want)
+					}
+					if err = lex.Restore(snap); err != nil {
+						t.Fatalf("%q %s: Restore() at %d: %v", input, name, n, err)
+					}
+					if got := formatResolved(fset, allTokens(lex)); got != want {
+						t.Errorf("%q %s %v: after Restore() at %d got\n%swant\n%s", input, name, keep, n, got, want)
+					}
+
+					fresh, freshSet := newLexer()
+					if err = fresh.Restore(snap); err != nil {
+						t.Fatalf("%q %s: Restore() of a new lexer at %d: %v", input, name, n, err)
+					}
+					if got := formatResolved(freshSet, allTokens(fresh)); got != want {
+						t.Errorf("%q %s %v: after Restore() of a new lexer at %d got\n%swant\n%s", input, name, keep, n, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Snapshot() needs a seekable source
+func TestSnapshotNotSeekable(t *testing.T) {
+	lex := lexer.New(lexWords, struct{ io.Reader }{strings.NewReader("a b")}, 1)
+	lex.NextToken()
+	if _, err := lex.Snapshot(); err != lexer.ErrNotSeekable {
+		t.Errorf("Snapshot() returned %v, want ErrNotSeekable", err)
+	}
+}
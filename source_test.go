@@ -0,0 +1,67 @@
+package lexer_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+func TestEmptySources(t *testing.T) {
+	lexers := map[string]func() lexer.Lexer{
+		"New":                  func() lexer.Lexer { return lexer.New(lexWords, strings.NewReader(""), 1) },
+		"NewSize":              func() lexer.Lexer { return lexer.NewSize(lexWords, bytes.NewReader(nil), 16, 1) },
+		"NewLimit":             func() lexer.Lexer { return lexer.NewLimit(lexWords, bytes.NewReader(nil), 16, lexer.TooLongSkip, 1) },
+		"NewFromString":        func() lexer.Lexer { return lexer.NewFromString(lexWords, "", 1) },
+		"NewFromBytes nil":     func() lexer.Lexer { return lexer.NewFromBytes(lexWords, nil, 1) },
+		"NewFromBytes empty":   func() lexer.Lexer { return lexer.NewFromBytes(lexWords, []byte{}, 1) },
+		"NewFromBytesNoCopy":   func() lexer.Lexer { return lexer.NewFromBytesNoCopy(lexWords, nil, 1) },
+		"FromReader":           func() lexer.Lexer { return lexer.NewWithOptions(lexWords, lexer.FromReader(bytes.NewReader(nil))) },
+		"FromBytes nil":        func() lexer.Lexer { return lexer.NewWithOptions(lexWords, lexer.FromBytes(nil)) },
+		"FromBytesNoCopy nil":  func() lexer.Lexer { return lexer.NewWithOptions(lexWords, lexer.FromBytesNoCopy(nil)) },
+		"FromString empty":     func() lexer.Lexer { return lexer.NewWithOptions(lexWords, lexer.FromString("")) },
+		"FromBytes nil trivia": func() lexer.Lexer { return lexer.NewWithOptions(lexWords, lexer.FromBytes(nil), lexer.KeepTrivia()) },
+	}
+	for name, newLexer := range lexers {
+		if tok := newLexer().NextToken(); !tok.EOF() || tok.Line() != 1 || tok.Column() != 1 {
+			t.Errorf("%s: got %v, want EOF@1:1", name, tok)
+		}
+	}
+	for _, input := range [][]byte{nil, {}} {
+		if tokens := lexer.NewIncremental(lexWords, input).Tokens(); len(tokens) != 1 || !tokens[0].EOF() {
+			t.Errorf("NewIncremental(%#v): got %v, want EOF", input, tokens)
+		}
+	}
+}
+
+// BufferSize and ChannelCap reject sizes that could never work, rather than
+// failing later in bufio or deadlocking
+func TestOptionRanges(t *testing.T) {
+	panics := map[string]func(){
+		"BufferSize(0)":  func() { lexer.BufferSize(0) },
+		"BufferSize(-1)": func() { lexer.BufferSize(-1) },
+		"ChannelCap(0)":  func() { lexer.ChannelCap(0) },
+		"NewSize 0":      func() { lexer.NewSize(lexWords, strings.NewReader("a"), 0, 1) },
+		"New 0":          func() { lexer.New(lexWords, strings.NewReader("a"), 0) },
+	}
+	for name, f := range panics {
+		func() {
+			defer func() {
+				if p := recover(); p == nil || !strings.HasPrefix(fmt.Sprint(p), "lexer: ") {
+					t.Errorf("%s: got panic %v, want a lexer: panic", name, p)
+				}
+			}()
+			f()
+		}()
+	}
+	lex := lexer.NewWithOptions(lexWords, lexer.FromReader(strings.NewReader("ab cd")), lexer.BufferSize(1), lexer.MaxTokenSize(-1))
+	var words []string
+	for tok := lex.NextToken(); !tok.EOF(); tok = lex.NextToken() {
+		words = append(words, string(tok.Bytes()))
+	}
+	if got := strings.Join(words, ","); got != "ab,cd" {
+		t.Errorf("BufferSize(1), MaxTokenSize(-1): got %q, want \"ab,cd\"", got)
+	}
+}
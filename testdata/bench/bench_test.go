@@ -0,0 +1,100 @@
+package lexer_test
+
+// This is a copy of the New/NextToken benchmarks in ../../bench_test.go that
+// needs nothing newer than lexertest.Benchmark, so that it can be run against
+// older trees as well as this one.  See PERFORMANCE in the README
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"unicode"
+
+	"github.com/iNamik/go_lexer"
+	"github.com/iNamik/go_lexer/lexertest"
+)
+
+const (
+	T_WORD lexer.TokenType = lexer.T_EOF + 1 + iota
+	T_NUM
+)
+
+var benchInput = func() []byte {
+	rnd := rand.New(rand.NewSource(1))
+	pieces := []string{"lexer", "state", "x", "token", "if", "return", "Größe", "naïve", "日本語", "42", "3.14", "0x1f", "==", "(", ")", "{", "}", ";"}
+	var b bytes.Buffer
+	for b.Len() < 1<<20 {
+		b.WriteString(pieces[rnd.Intn(len(pieces))])
+		if rnd.Intn(8) == 0 {
+			b.WriteByte('\n')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.Bytes()
+}()
+
+func lexRunes(l lexer.Lexer) lexer.StateFn {
+	r := l.NextRune()
+	switch {
+	case r == lexer.RuneEOF:
+		l.EmitEOF()
+		return nil
+	case r == '\n':
+		l.NewLine()
+		l.IgnoreToken()
+	case r == ' ':
+		l.IgnoreToken()
+	case unicode.IsLetter(r):
+		for unicode.IsLetter(l.PeekRune(0)) || unicode.IsDigit(l.PeekRune(0)) {
+			l.NextRune()
+		}
+		l.EmitTokenWithBytes(T_WORD)
+	case unicode.IsDigit(r):
+		for r = l.NextRune(); unicode.IsDigit(r) || r == '.' || r == 'x' || unicode.Is(unicode.ASCII_Hex_Digit, r); r = l.NextRune() {
+		}
+		l.BackupRune()
+		l.EmitTokenWithBytes(T_NUM)
+	default:
+		if r == '=' && l.PeekRune(0) == '=' {
+			l.NextRune()
+		}
+		l.EmitToken(lexer.T_UNKNOWN)
+	}
+	return lexRunes
+}
+
+var (
+	spaceBytes  = []byte(" ")
+	numberBytes = []byte("0123456789.xabcdef")
+	blankBytes  = []byte(" \n")
+)
+
+func lexMatchers(l lexer.Lexer) lexer.StateFn {
+	switch {
+	case l.MatchEOF():
+		l.EmitEOF()
+		return nil
+	case l.MatchOneRune('\n'):
+		l.NewLine()
+		l.IgnoreToken()
+	case l.MatchOneOrMoreBytes(spaceBytes):
+		l.IgnoreToken()
+	case l.MatchOneOrMoreFunc(unicode.IsLetter):
+		l.EmitTokenWithBytes(T_WORD)
+	case l.MatchOneOrMoreBytes(numberBytes):
+		l.EmitTokenWithBytes(T_NUM)
+	default:
+		l.NonMatchOneOrMoreBytes(blankBytes)
+		l.EmitToken(lexer.T_UNKNOWN)
+	}
+	return lexMatchers
+}
+
+func BenchmarkRunes(b *testing.B) {
+	b.Run("New/NextToken", func(b *testing.B) { lexertest.Benchmark(b, lexRunes, benchInput) })
+}
+
+func BenchmarkMatchers(b *testing.B) {
+	b.Run("New/NextToken", func(b *testing.B) { lexertest.Benchmark(b, lexMatchers, benchInput) })
+}
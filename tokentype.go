@@ -0,0 +1,72 @@
+package lexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	tokenTypesMu   sync.RWMutex
+	tokenTypeNames = map[TokenType]string{T_LEX_ERR: "LEX_ERR", T_UNKNOWN: "UNKNOWN", T_EOF: "EOF"}
+	nextTokenType  = firstRegisteredType
+)
+
+// firstRegisteredType is the first TokenType allocated by RegisterTokenType,
+// far above any type a lexer is likely to declare using iota
+const firstRegisteredType TokenType = 1 << 30
+
+// RegisterTokenType allocates a new, unique TokenType with the specified name.
+// The name is used by TokenType.String() and Token.String().
+// It is meant to be used when declaring a lexer's token types, i.e.
+//
+//	var T_IDENT = lexer.RegisterTokenType("IDENT")
+//
+// Registered types are allocated upwards from 1<<30, so they don't collide
+// with types declared using iota from T_EOF+1, even in the same program.
+func RegisterTokenType(name string) TokenType {
+	tokenTypesMu.Lock()
+	defer tokenTypesMu.Unlock()
+	t := nextTokenType
+	nextTokenType++
+	tokenTypeNames[t] = name
+	return t
+}
+
+// String returns the registered name of the TokenType, or TokenType(n) if
+// the type was not registered
+func (t TokenType) String() string {
+	tokenTypesMu.RLock()
+	name, ok := tokenTypeNames[t]
+	tokenTypesMu.RUnlock()
+	if ok {
+		return name
+	}
+	return "TokenType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// String returns a readable representation of the token, i.e.
+// IDENT("foo")@3:7, omitting the bytes if there are none, and followed by
+// the value if there is one, i.e. INT("0x2a")=42@1:1.  String values are
+// quoted, i.e. STR="a\n"@1:1
+func (t *Token) String() string {
+	s := t.typ.String()
+	if t.bytes != nil {
+		s += fmt.Sprintf("(%q)", t.bytes)
+	}
+	if v := t.Value(); v != nil {
+		s += "=" + formatValue(v)
+	}
+	return fmt.Sprintf("%s@%d:%d", s, t.line, t.column)
+}
+
+// formatValue formats a token value on one line, quoting strings and any
+// value whose formatting spans lines
+func formatValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if _, ok := v.(string); ok || strings.ContainsAny(s, "\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,28 @@
+package lexer_test
+
+import (
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// Registered types must not collide with types declared using iota
+func TestRegisterTokenTypeAvoidsIota(t *testing.T) {
+	const (
+		T_A lexer.TokenType = lexer.T_EOF + 1 + iota
+		T_B
+	)
+	registered := lexer.RegisterTokenType("REGISTERED")
+	if registered == T_A || registered == T_B || registered < 1<<30 {
+		t.Errorf("RegisterTokenType() = %d, which may collide with iota types", int(registered))
+	}
+	if again := lexer.RegisterTokenType("AGAIN"); again == registered {
+		t.Errorf("RegisterTokenType() returned %d twice", int(again))
+	}
+	if got := T_A.String(); got != "TokenType(1)" {
+		t.Errorf("T_A.String() = %q, want \"TokenType(1)\"", got)
+	}
+	if got := registered.String(); got != "REGISTERED" {
+		t.Errorf("registered.String() = %q, want \"REGISTERED\"", got)
+	}
+}
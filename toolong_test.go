@@ -0,0 +1,77 @@
+package lexer_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// TooLongSkip drops the whole token with one error, whatever its length,
+// and nothing else: at exact multiples of the limit there is no rest of the
+// token to drop
+func TestTooLongSkip(t *testing.T) {
+	for _, n := range []int{32, 33, 63, 64, 65, 100, 1000} {
+		input := "ab " + strings.Repeat("x", n) + " cd"
+		for _, c := range []struct {
+			start lexer.StateFn
+			want  []string
+		}{
+			{lexWords, []string{`WORD("ab")@1:1`, `LEX_ERR("lexer: token too long")@1:4`, fmt.Sprintf(`WORD("cd")@1:%d`, n+5)}},
+			{lexSpaces, []string{`WORD("ab")@1:1`, `SPACE(" ")@1:3`, `LEX_ERR("lexer: token too long")@1:4`, fmt.Sprintf(`SPACE(" ")@1:%d`, n+4), fmt.Sprintf(`WORD("cd")@1:%d`, n+5)}},
+		} {
+			lex := lexer.NewLimit(c.start, strings.NewReader(input), 32, lexer.TooLongSkip, 1)
+			var got []string
+			for tok := lex.NextToken(); !tok.EOF(); tok = lex.NextToken() {
+				got = append(got, tok.String())
+			}
+			if strings.Join(got, " ") != strings.Join(c.want, " ") {
+				t.Errorf("%d: got %v, want %v", n, got, c.want)
+			}
+		}
+	}
+}
+
+// lexComments emits /*...*/ comments, and words
+func lexComments(l lexer.Lexer) lexer.StateFn {
+	if l.PeekRune(0) == '/' && l.PeekRune(1) == '*' {
+		return lexComment
+	}
+	if lexSpaces(l) == nil {
+		return nil
+	}
+	return lexComments
+}
+
+// lexComment emits a comment, or the rest of one that was too long
+func lexComment(l lexer.Lexer) lexer.StateFn {
+	l.MatchUntilString("*/", true)
+	l.EmitToken(T_BODY)
+	return lexComments
+}
+
+// Lines within the bytes discarded with a token that was too long are
+// counted
+func TestTooLongSkipLines(t *testing.T) {
+	// MatchUntilString() overflows with the newlines examined, but not
+	// consumed
+	comment := "/*" + strings.Repeat("a", 26) + "\n\n\n\n" + strings.Repeat("b", 10) + "*/"
+	fset := lexer.NewFileSet()
+	lex := lexer.NewWithOptions(lexComments, lexer.FromReader(strings.NewReader("ab\n"+comment+"\ncd")),
+		lexer.MaxTokenSize(32), lexer.OnTokenTooLong(lexer.TooLongSkip), lexer.Newlines(lexer.NewlineLF), lexer.WithFileSet(fset))
+	var got []string
+	for tok := lex.NextToken(); !tok.EOF(); tok = lex.NextToken() {
+		got = append(got, fmt.Sprintf("%v %v", tok, fset.Position(tok.Pos())))
+	}
+	want := []string{
+		`WORD("ab")@1:1 1:1`,
+		`SPACE("\n")@1:3 1:3`,
+		`LEX_ERR("lexer: token too long")@2:1 2:1`,
+		`SPACE("\n")@6:13 6:13`,
+		`WORD("cd")@7:1 7:1`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
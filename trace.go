@@ -0,0 +1,99 @@
+package lexer
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+)
+
+// Tracer receives notifications as the lexer runs, giving visibility into
+// which StateFn ran, what it consumed and what it emitted
+type Tracer interface {
+
+	// TraceState is called before each state function is entered
+	TraceState(name string)
+
+	// TraceNextRune is called each time NextRune consumes a rune (or RuneEOF),
+	// with the position of the rune
+	TraceNextRune(r rune, line int, column int)
+
+	// TraceBackup is called each time BackupRunes un-consumes runes, with the
+	// position of the next rune
+	TraceBackup(n int, line int, column int)
+
+	// TraceReset is called each time the lexer is reset to a marker
+	TraceReset(m *Marker)
+
+	// TraceEmit is called for each emitted token, including errors and EOF
+	TraceEmit(t *Token)
+
+	// TraceIgnore is called each time consumed bytes are ignored
+	TraceIgnore(b []byte)
+}
+
+// Trace attaches a tracer to a newly constructed lexer, returning the lexer.
+// It panics if the lexer has already started producing tokens.
+//
+//	lex := lexer.Trace(lexer.NewFromString(lexFunc, input, 1), lexer.NewLogTracer(os.Stderr))
+func Trace(lex Lexer, tracer Tracer) Lexer {
+	l, ok := lex.(*lexer)
+	if !ok {
+		panic("lexer: Trace requires a lexer created by this package")
+	}
+	if l.sequence != 0 || l.pos != 0 {
+		panic("lexer: Trace called after lexer has started")
+	}
+	l.tracer = tracer
+	return l
+}
+
+// stateName returns the name of the function behind a StateFn
+func stateName(state StateFn) string {
+	if state == nil {
+		return "<nil>"
+	}
+	if fn := runtime.FuncForPC(reflect.ValueOf(state).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "<unknown>"
+}
+
+// logTracer writes a human-readable, indented log of lexer activity
+type logTracer struct {
+	w io.Writer
+}
+
+// NewLogTracer returns a Tracer that writes a human-readable log to w, with
+// the activity of each state indented beneath the state's name
+func NewLogTracer(w io.Writer) Tracer {
+	return &logTracer{w: w}
+}
+
+func (t *logTracer) TraceState(name string) {
+	fmt.Fprintf(t.w, "state %s\n", name)
+}
+
+func (t *logTracer) TraceNextRune(r rune, line int, column int) {
+	if r == RuneEOF {
+		fmt.Fprintf(t.w, "    next EOF @%d:%d\n", line, column)
+	} else {
+		fmt.Fprintf(t.w, "    next %q @%d:%d\n", r, line, column)
+	}
+}
+
+func (t *logTracer) TraceBackup(n int, line int, column int) {
+	fmt.Fprintf(t.w, "    backup %d @%d:%d\n", n, line, column)
+}
+
+func (t *logTracer) TraceReset(m *Marker) {
+	fmt.Fprintf(t.w, "    reset @%d:%d\n", m.line, m.column+1)
+}
+
+func (t *logTracer) TraceEmit(tok *Token) {
+	fmt.Fprintf(t.w, "    emit %s\n", tok)
+}
+
+func (t *logTracer) TraceIgnore(b []byte) {
+	fmt.Fprintf(t.w, "    ignore %q\n", b)
+}
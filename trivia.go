@@ -0,0 +1,61 @@
+package lexer
+
+import (
+	"bytes"
+)
+
+// Lexer::EmitTrivia
+func (l *lexer) EmitTrivia(t TokenType) {
+	if l.overflowed(t) {
+		return
+	}
+	if l.tracer != nil {
+		l.tracer.TraceIgnore(l.PeekTokenBytes())
+	}
+	l.trivium(t)
+}
+
+// trivium consumes the current token as trivia of the specified type.  It is
+// kept only with the KeepTrivia option, and only if not empty
+func (l *lexer) trivium(t TokenType) {
+	if !l.keepTrivia || l.tokenLen == 0 {
+		l.consume(false)
+		return
+	}
+
+	line, column := l.tokenStart()
+
+	offset := l.offset
+
+	b := l.consume(true)
+
+	tok := l.token(t, b, line, column, offset)
+
+	if !l.holding {
+		l.trivia = append(l.trivia, *tok)
+		return
+	}
+	// Trivia up to and including the end of the line trails the held token
+	l.held.setExtra(l.held.LeadingTrivia(), append(l.held.TrailingTrivia(), *tok), l.held.Value())
+	if bytes.ContainsAny(b, "\r\n") {
+		l.holding = false
+		l.deliver(&l.held)
+	}
+}
+
+// sendWithTrivia gives the token any trivia collected before it, holding it
+// until its trailing trivia is known
+func (l *lexer) sendWithTrivia(token *Token) {
+	if l.holding {
+		l.holding = false
+		l.deliver(&l.held)
+	}
+	token.setExtra(l.trivia, nil, token.Value())
+	l.trivia = nil
+	if token.typ == T_EOF {
+		l.deliver(token)
+	} else {
+		l.held = *token
+		l.holding = true
+	}
+}
@@ -0,0 +1,71 @@
+package lexer_test
+
+import (
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// lexLineTrivia emits words, and runs of spaces and each newline as T_SPACE
+// trivia
+func lexLineTrivia(l lexer.Lexer) lexer.StateFn {
+	switch {
+	case l.MatchEOF():
+		l.EmitEOF()
+		return nil
+	case l.MatchOneOrMoreBytes([]byte(" \t")), l.MatchOneRune('\n'):
+		l.EmitTrivia(T_SPACE)
+	default:
+		l.NonMatchOneOrMoreBytes(blanks)
+		l.EmitTokenWithBytes(T_WORD)
+	}
+	return lexLineTrivia
+}
+
+// Trivia trails a token up to and including the end of its line, and leads
+// the next token, or EOF
+func TestKeepTrivia(t *testing.T) {
+	for _, c := range []struct {
+		input string
+		want  string
+	}{
+		{"", "EOF@1:1[0,0)\n"},
+		{"  ", "EOF@1:3[2,2)\n< SPACE(\"  \")@1:1[0,2)\n"},
+		{"a b", "WORD(\"a\")@1:1[0,1)\n> SPACE(\" \")@1:2[1,2)\nWORD(\"b\")@1:3[2,3)\nEOF@1:4[3,3)\n"},
+		{"a \n b\n", "WORD(\"a\")@1:1[0,1)\n> SPACE(\" \")@1:2[1,2)\n> SPACE(\"\\n\")@1:3[2,3)\n" +
+			"WORD(\"b\")@2:2[4,5)\n< SPACE(\" \")@2:1[3,4)\n> SPACE(\"\\n\")@2:3[5,6)\nEOF@3:1[6,6)\n"},
+		{"a\n\n  ", "WORD(\"a\")@1:1[0,1)\n> SPACE(\"\\n\")@1:2[1,2)\n" +
+			"EOF@3:3[5,5)\n< SPACE(\"\\n\")@2:1[2,3)\n< SPACE(\"  \")@3:1[3,5)\n"},
+	} {
+		for source := range sources {
+			lex, _ := newSourceLexer(lexLineTrivia, source, c.input, lexer.KeepTrivia())
+			if got := formatWithTrivia(allTokens(lex)); got != c.want {
+				t.Errorf("%q %s: got\n%swant\n%s", c.input, source, got, c.want)
+			}
+		}
+	}
+}
+
+// A state may emit any number of tokens, whatever ChannelCap
+func TestManyTokensPerState(t *testing.T) {
+	input := "a b\n\tc  d\t e\n\n\tf g h i j\n"
+	lexAll := func(l lexer.Lexer) lexer.StateFn {
+		for lexWords(l) != nil {
+		}
+		return nil
+	}
+	for source := range sources {
+		for _, keep := range []bool{false, true} {
+			opts := []lexer.Option{lexer.ChannelCap(1)}
+			if keep {
+				opts = append(opts, lexer.KeepTrivia())
+			}
+			lex, _ := newSourceLexer(lexWords, source, input, opts...)
+			want := formatWithTrivia(allTokens(lex))
+			lex, _ = newSourceLexer(lexAll, source, input, opts...)
+			if got := formatWithTrivia(allTokens(lex)); got != want {
+				t.Errorf("%s %v: got\n%swant\n%s", source, keep, got, want)
+			}
+		}
+	}
+}
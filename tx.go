@@ -0,0 +1,92 @@
+package lexer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// transaction records the state of the lexer at Begin()
+type transaction struct {
+	offset   int
+	line     int
+	column   int
+	prevRune rune
+	modes    []StateFn
+	eof      bool
+	tokens   int // len(txTokens) at Begin()
+	bytes    int // len(txBytes) at Begin()
+	inputs   int // len(inputs) at Begin()
+	trivia   []Token
+	held     Token
+	holding  bool
+}
+
+// Lexer::Begin
+func (l *lexer) Begin() {
+	if l.tokenLen > 0 {
+		panic("illegal state: Begin() called with a pending token")
+	}
+	l.txs = append(l.txs, transaction{
+		offset:   l.offset,
+		line:     l.line,
+		column:   l.column,
+		prevRune: l.prevRune,
+		modes:    append([]StateFn(nil), l.modes...),
+		eof:      l.eof,
+		tokens:   len(l.txTokens),
+		bytes:    len(l.txBytes),
+		inputs:   len(l.inputs),
+		trivia:   l.trivia[:len(l.trivia):len(l.trivia)],
+		held:     l.held,
+		holding:  l.holding,
+	})
+}
+
+// Lexer::Commit
+func (l *lexer) Commit() {
+	if len(l.txs) == 0 {
+		panic("Underflow Exception")
+	}
+	l.txs = l.txs[:len(l.txs)-1]
+	if len(l.txs) > 0 {
+		return
+	}
+	tokens := l.txTokens
+	l.txTokens = nil
+	l.txBytes = l.txBytes[:0]
+	for i := range tokens {
+		l.deliver(&tokens[i])
+	}
+}
+
+// Lexer::Rollback
+func (l *lexer) Rollback() {
+	if len(l.txs) == 0 {
+		panic("Underflow Exception")
+	}
+	tx := &l.txs[len(l.txs)-1]
+	if tx.inputs != len(l.inputs) {
+		panic("illegal state: Rollback() called across PushInput()")
+	}
+	l.txTokens = l.txTokens[:tx.tokens]
+	// Replay bytes consumed from the reader ahead of what remains in it
+	if !l.fromBytes && len(l.txBytes) > tx.bytes {
+		replay := io.MultiReader(bytes.NewReader(append([]byte(nil), l.txBytes[tx.bytes:]...)), l.reader)
+		l.txBytes = l.txBytes[:tx.bytes]
+		l.ioReader = replay
+		l.reader = bufio.NewReaderSize(replay, l.bufLen)
+	}
+	l.offset = tx.offset
+	l.line = tx.line
+	l.column = tx.column
+	l.prevRune = tx.prevRune
+	l.modes = append(l.modes[:0], tx.modes...)
+	l.eof = tx.eof
+	l.trivia = tx.trivia
+	l.held = tx.held
+	l.holding = tx.holding
+	l.txs = l.txs[:len(l.txs)-1]
+
+	l.resetInput()
+}
@@ -0,0 +1,115 @@
+package lexer_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+// newSourceLexer returns a lexer of input from one of sources, with small
+// buffers for all but "bytes" and "reader"
+func newSourceLexer(start lexer.StateFn, source string, input string, opts ...lexer.Option) (lexer.Lexer, *lexer.FileSet) {
+	fset := lexer.NewFileSet()
+	opts = append(opts, lexer.Newlines(lexer.NewlineLF), lexer.TabWidth(4), lexer.WithFileSet(fset))
+	if source != "bytes" && source != "reader" {
+		opts = append(opts, lexer.BufferSize(8))
+	}
+	return lexer.NewWithOptions(start, sources[source]([]byte(input)), opts...), fset
+}
+
+// lexTentative returns a lexer that lexes as lexWords does, but at random
+// begins, commits and rolls back nested transactions between words, rolling
+// back across EOF as well
+func lexTentative(rnd *rand.Rand) lexer.StateFn {
+	depth := 0
+	var state lexer.StateFn
+	state = func(l lexer.Lexer) lexer.StateFn {
+		switch n := rnd.Intn(10); {
+		case n < 2 && depth < 3:
+			l.Begin()
+			depth++
+		case n < 4 && depth > 0:
+			l.Commit()
+			depth--
+		case n < 6 && depth > 0:
+			l.Rollback()
+			depth--
+		default:
+			if lexWords(l) != nil {
+				break
+			}
+			if depth > 0 && rnd.Intn(2) == 0 {
+				l.Rollback()
+				depth--
+				break
+			}
+			for ; depth > 0; depth-- {
+				l.Commit()
+			}
+			return nil
+		}
+		return state
+	}
+	return state
+}
+
+// Whatever is rolled back is lexed again, so the tokens, their trivia and
+// their positions must be those of lexing without transactions
+func TestTransactions(t *testing.T) {
+	inputs := []string{
+		"",
+		"a",
+		"ab cd\nef",
+		"a b\n\tc  d\t e\n\n\tf g h i j\n",
+		strings.Repeat("word\t", 20) + "\n" + strings.Repeat("x ", 30),
+	}
+	for _, input := range inputs {
+		for source := range sources {
+			for _, keep := range []bool{false, true} {
+				var opts []lexer.Option
+				if keep {
+					opts = append(opts, lexer.KeepTrivia())
+				}
+				lex, fset := newSourceLexer(lexWords, source, input, opts...)
+				want := formatResolved(fset, allTokens(lex))
+				for seed := int64(0); seed < 20; seed++ {
+					lex, fset := newSourceLexer(lexTentative(rand.New(rand.NewSource(seed))), source, input, opts...)
+					if got := formatResolved(fset, allTokens(lex)); got != want {
+						t.Fatalf("%q %s %v seed %d: got\n%swant\n%s", input, source, keep, seed, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// lexAllOrNothing lexes words and EOF in a transaction that it rolls back,
+// then emits the whole input as one T_BODY
+func lexAllOrNothing(l lexer.Lexer) lexer.StateFn {
+	l.Begin()
+	for lexWords(l) != nil {
+	}
+	l.Rollback()
+	l.MatchZeroOrMoreFunc(func(rune) bool { return true })
+	l.EmitTokenWithBytes(T_BODY)
+	l.EmitEOF()
+	return nil
+}
+
+// After Rollback() across EOF, a reader's bytes must be read again
+func TestRollbackEOF(t *testing.T) {
+	input := "ab cd\n\tef gh"
+	want := `BODY("ab cd\n\tef gh")@1:1 1:1` + "\n" + `EOF@2:10 2:10` + "\n"
+	for source := range sources {
+		lex, fset := newSourceLexer(lexAllOrNothing, source, input, lexer.KeepTrivia())
+		got := ""
+		for _, tok := range allTokens(lex) {
+			got += tok.String() + " " + fset.Position(tok.Pos()).String() + "\n"
+		}
+		if got != want {
+			t.Errorf("%s: got\n%swant\n%s", source, got, want)
+		}
+	}
+}
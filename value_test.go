@@ -0,0 +1,74 @@
+package lexer_test
+
+import (
+	"testing"
+
+	"github.com/iNamik/go_lexer"
+)
+
+var decodeNumbers = func() lexer.NumberSpec {
+	spec := lexer.GoNumbers
+	spec.Decode = true
+	return spec
+}()
+
+// lexSpeculative tries a number, falling back to a word if letters follow it
+func lexSpeculative(backup bool) lexer.StateFn {
+	var state lexer.StateFn
+	state = func(l lexer.Lexer) lexer.StateFn {
+		if l.MatchEOF() {
+			l.EmitEOF()
+			return nil
+		}
+		m := l.Marker()
+		if l.MatchNumber(decodeNumbers) != lexer.NumberNone && l.PeekRune(0) == lexer.RuneEOF {
+			l.EmitTokenWithBytes(T_NUM)
+			return state
+		}
+		if backup {
+			l.BackupRunes(len(l.PeekTokenBytes()))
+		} else {
+			l.Reset(m)
+		}
+		l.NonMatchOneOrMoreRunes([]rune{' '})
+		l.EmitTokenWithBytes(T_WORD)
+		return state
+	}
+	return state
+}
+
+// A decoded value is dropped when its runes are reset or backed up
+func TestValueReset(t *testing.T) {
+	for _, backup := range []bool{false, true} {
+		tok := lexer.NewFromString(lexSpeculative(backup), "34abc", 1).NextToken()
+		if tok.Type() != T_WORD || tok.Value() != nil {
+			t.Errorf("backup %v: got %v, want WORD without a value", backup, tok)
+		}
+		tok = lexer.NewFromString(lexSpeculative(backup), "34", 1).NextToken()
+		if tok.Type() != T_NUM || tok.Value() != int64(34) {
+			t.Errorf("backup %v: got %v, want NUM with value 34", backup, tok)
+		}
+	}
+}
+
+// String values are quoted, keeping each token on one line
+func TestTokenStringValue(t *testing.T) {
+	spec := lexer.GoEscapes
+	spec.Decode = true
+	lex := lexer.NewFromString(func(l lexer.Lexer) lexer.StateFn {
+		l.MatchQuoted('"', spec)
+		l.EmitToken(lexer.T_UNKNOWN)
+		return nil
+	}, `"a\nb"`, 1)
+	if got, want := lex.NextToken().String(), `UNKNOWN="a\nb"@1:1`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	lex = lexer.NewFromString(func(l lexer.Lexer) lexer.StateFn {
+		l.NextRune()
+		l.EmitTokenWithValue(lexer.T_UNKNOWN, 42)
+		return nil
+	}, "x", 1)
+	if got, want := lex.NextToken().String(), `UNKNOWN=42@1:1`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}